@@ -0,0 +1,360 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"gitlab.com/angel-afonso/gitlabcli/api"
+	"gitlab.com/angel-afonso/gitlabcli/auth"
+	"gitlab.com/angel-afonso/gitlabcli/utils"
+	"gopkg.in/gookit/color.v1"
+)
+
+// sessionOptions returns the profile selection from the global flags
+// and the remote of the current repository
+func sessionOptions(context *cli.Context) auth.Options {
+	return auth.Options{
+		Host:    context.String("host"),
+		Profile: context.String("profile"),
+		Remote:  utils.RemoteHost(),
+	}
+}
+
+// newClient returns a client of the session configured with the global flags
+func newClient(context *cli.Context, session *auth.Session) api.Client {
+	client := api.NewClient(session)
+
+	if context.IsSet("retries") {
+		client.Retries = context.Int("retries")
+	}
+
+	client.Timeout = context.Duration("timeout")
+
+	if context.Bool("debug") || debugEnv() {
+		client.Debug = os.Stderr
+	}
+
+	if file := context.String("debug-file"); file != "" {
+		client.Trace = api.NewTrace(file, context.App.Version)
+	}
+
+	return client
+}
+
+// debugEnv returns true if GITLABCLI_DEBUG enables the request log, boolean
+// values like 0 or false are parsed and any other value enables it
+func debugEnv() bool {
+	value := os.Getenv("GITLABCLI_DEBUG")
+
+	if enabled, err := strconv.ParseBool(value); err == nil {
+		return enabled
+	}
+
+	return value != ""
+}
+
+// Authenticate open the session selected for this run
+// and set up the client with it
+func Authenticate(client *api.Client) func(*cli.Context) error {
+	return func(context *cli.Context) error {
+		if err := Configure(context); err != nil {
+			return err
+		}
+
+		*client = newClient(context, auth.OpenSession(sessionOptions(context)))
+		return nil
+	}
+}
+
+// Login authenticate with the browser, the device flow or with a personal access token
+// and store the session
+func Login(context *cli.Context) error {
+	options := sessionOptions(context)
+
+	if Device {
+		_, err := auth.LoginWithDevice(options)
+		return err
+	}
+
+	if !context.IsSet("token") {
+		_, err := auth.Login(options)
+		return err
+	}
+
+	token := Token
+
+	if token == "-" {
+		var err error
+
+		if token, err = utils.ReadPassword("Personal access token: "); err != nil {
+			return err
+		}
+	}
+
+	_, err := auth.LoginWithToken(options, token)
+	return err
+}
+
+// ProfileList display the stored profiles
+func ProfileList(context *cli.Context) error {
+	profiles, active, err := auth.Profiles()
+
+	if err != nil {
+		return err
+	}
+
+	if len(profiles) == 0 {
+		color.Red.Println("There are no profiles, login with gitlabcli auth login")
+		return nil
+	}
+
+	for _, profile := range profiles {
+		if profile.Profile == active {
+			color.Green.Print("* ")
+		} else {
+			fmt.Print("  ")
+		}
+
+		color.Bold.Print(profile.Profile)
+		color.OpItalic.Printf(" (%s)\n", profile.Host)
+	}
+
+	return nil
+}
+
+// Logout revoke the token and remove the selected profile,
+// or every profile with --all
+func Logout(context *cli.Context) error {
+	results, err := auth.Logout(sessionOptions(context), LogoutAll)
+
+	if err == auth.ErrNoSession {
+		color.Red.Println("Session does not exist")
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	for _, result := range results {
+		if result.Revoked != nil {
+			color.Yellow.Printf("Token of %s not revoked: %s\n", result.Session.Profile, result.Revoked.Error())
+		}
+
+		color.Success.Printf("Logged out of %s (%s)\n", result.Session.Profile, result.Session.Host)
+	}
+
+	return nil
+}
+
+// EncryptSession encrypts the tokens stored in plaintext
+func EncryptSession(context *cli.Context) error {
+	if err := auth.Encrypt(); err != nil {
+		return err
+	}
+
+	color.Success.Println("Session encrypted")
+	return nil
+}
+
+// SwitchProfile set the active profile
+func SwitchProfile(context *cli.Context) error {
+	name := context.Args().First()
+
+	if name == "" {
+		return errors.New("profile name is required")
+	}
+
+	if err := auth.Switch(name); err != nil {
+		return err
+	}
+
+	color.Success.Printf("Switched to %s\n", name)
+	return nil
+}
+
+// tokenDetails returns the scopes and the expiration of the session token
+func tokenDetails(ctx context.Context, client *api.Client) ([]string, string) {
+	session := client.Session()
+
+	switch session.Type {
+	case auth.JobToken:
+		return nil, "end of the job"
+	case auth.PrivateToken:
+		var token struct {
+			Scopes    []string
+			ExpiresAt string `json:"expires_at"`
+		}
+
+		if err := client.GetContext(ctx, "personal_access_tokens/self", &token); err != nil {
+			return nil, "unknown"
+		}
+
+		return token.Scopes, utils.Ternary(token.ExpiresAt != "", token.ExpiresAt, "never").(string)
+	default:
+		if client.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, client.Timeout)
+			defer cancel()
+		}
+
+		info, err := auth.InspectContext(ctx, session)
+
+		if err != nil {
+			return nil, "unknown"
+		}
+
+		return info.Scopes, oauthExpiry(session.Expiry, info.ExpiresIn, time.Now())
+	}
+}
+
+// oauthExpiry returns the expiration of an oauth token, from the stored expiry
+// or the seconds left reported by gitlab. Tokens without both never expire
+func oauthExpiry(stored time.Time, expiresIn int64, now time.Time) string {
+	if !stored.IsZero() {
+		return stored.Format(time.RFC1123)
+	}
+
+	if expiresIn == 0 {
+		return "never"
+	}
+
+	return now.Add(time.Duration(expiresIn) * time.Second).Format(time.RFC1123)
+}
+
+// AuthStatus display the user, host, scopes and expiration of the session,
+// exits with error when the token is not valid
+func AuthStatus(context *cli.Context) error {
+	session, err := auth.LookUp(sessionOptions(context))
+
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+
+	client := newClient(context, session)
+	spinner := utils.ShowSpinner()
+	defer spinner.Stop()
+
+	var user User
+
+	if session.Type == auth.JobToken {
+		var job struct {
+			User User
+		}
+
+		err = client.GetContext(context.Context, "job", &job)
+		user = job.User
+	} else {
+		err = client.GetContext(context.Context, "user", &user)
+	}
+
+	var scopes []string
+	var expiry string
+
+	if err == nil {
+		scopes, expiry = tokenDetails(context.Context, &client)
+	}
+
+	spinner.Stop()
+
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("The token for %s is not valid: %s", session.Host, err.Error()), 1)
+	}
+
+	fmt.Printf("Logged in to %s as ", color.Bold.Sprint(session.Host))
+	user.Print()
+	println()
+
+	if session.Profile != "" {
+		fmt.Printf("Profile: %s\n", session.Profile)
+	} else {
+		fmt.Println("Profile: environment")
+	}
+
+	fmt.Printf("Token type: %s\n", session.Type)
+	fmt.Printf("Scopes: %s\n", utils.Ternary(len(scopes) > 0, strings.Join(scopes, ", "), "unknown"))
+	fmt.Printf("Expires: %s\n", expiry)
+	println()
+	color.Success.Println("Token is valid")
+
+	return nil
+}
+
+// GitCredential answer git credential helper requests with the stored session,
+// store and erase requests are ignored because the session is handled by gitlabcli
+func GitCredential(context *cli.Context) error {
+	if context.Args().First() != "get" {
+		return nil
+	}
+
+	credential := auth.ReadCredential(os.Stdin)
+	session, err := auth.Credential(credential)
+
+	if err != nil || session == nil {
+		return err
+	}
+
+	auth.WriteCredential(os.Stdout, credential, session)
+	return nil
+}
+
+// gitConfig change the global git configuration, replaced in tests
+var gitConfig = func(args ...string) error {
+	return exec.Command("git", append([]string{"config", "--global"}, args...)...).Run()
+}
+
+// SetupGit register gitlabcli as git credential helper for the given host,
+// or for the hosts of every stored profile
+func SetupGit(context *cli.Context) error {
+	var hosts []string
+
+	if host := context.String("host"); host != "" {
+		hosts = append(hosts, host)
+	} else {
+		profiles, _, err := auth.Profiles()
+
+		if err != nil {
+			return err
+		}
+
+		for _, profile := range profiles {
+			hosts = append(hosts, profile.Host)
+		}
+	}
+
+	if len(hosts) == 0 {
+		return errors.New("There are no profiles, login with gitlabcli auth login")
+	}
+
+	executable, err := os.Executable()
+
+	if err != nil {
+		return err
+	}
+
+	helper := fmt.Sprintf("!'%s' auth git-credential", executable)
+
+	for _, host := range hosts {
+		key := fmt.Sprintf("credential.%s.helper", auth.CredentialURL(host))
+
+		// the empty helper resets the helpers configured for every host
+		if err := gitConfig("--replace-all", key, ""); err != nil {
+			return err
+		}
+
+		if err := gitConfig("--add", key, helper); err != nil {
+			return err
+		}
+
+		color.Success.Printf("Configured git credential helper for %s\n", auth.CredentialURL(host))
+	}
+
+	return nil
+}
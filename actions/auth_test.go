@@ -0,0 +1,145 @@
+package actions
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli/v2"
+)
+
+func TestOAuthExpiry(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	stored := time.Date(2020, 6, 2, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, "never", oauthExpiry(time.Time{}, 0, now))
+	assert.Equal(t, "Mon, 01 Jun 2020 14:00:00 UTC", oauthExpiry(time.Time{}, 7200, now))
+	assert.Equal(t, "Tue, 02 Jun 2020 12:00:00 UTC", oauthExpiry(stored, 0, now))
+	assert.Equal(t, "Tue, 02 Jun 2020 12:00:00 UTC", oauthExpiry(stored, 7200, now))
+}
+
+func TestSetupGit(t *testing.T) {
+	var commands [][]string
+
+	defer func(original func(...string) error) { gitConfig = original }(gitConfig)
+
+	gitConfig = func(args ...string) error {
+		commands = append(commands, args)
+		return nil
+	}
+
+	set := flag.NewFlagSet("setup-git", flag.ContinueOnError)
+	set.String("host", "git.corp.example.com", "")
+
+	assert.NoError(t, SetupGit(cli.NewContext(cli.NewApp(), set, nil)))
+
+	executable, _ := os.Executable()
+	helper := fmt.Sprintf("!'%s' auth git-credential", executable)
+
+	assert.Equal(t, [][]string{
+		{"--replace-all", "credential.https://git.corp.example.com.helper", ""},
+		{"--add", "credential.https://git.corp.example.com.helper", helper},
+	}, commands)
+}
+
+func TestDebugEnv(t *testing.T) {
+	value, set := os.LookupEnv("GITLABCLI_DEBUG")
+	defer func() {
+		if set {
+			os.Setenv("GITLABCLI_DEBUG", value)
+		} else {
+			os.Unsetenv("GITLABCLI_DEBUG")
+		}
+	}()
+
+	cases := map[string]bool{
+		"":      false,
+		"0":     false,
+		"false": false,
+		"FALSE": false,
+		"1":     true,
+		"true":  true,
+		"yes":   true,
+		"on":    true,
+	}
+
+	for env, expected := range cases {
+		os.Setenv("GITLABCLI_DEBUG", env)
+		assert.Equal(t, expected, debugEnv(), env)
+	}
+}
+
+// statusContext returns the context of auth status for the fake instance,
+// with a personal access token in GITLAB_TOKEN
+func statusContext(t *testing.T, handler http.HandlerFunc) *cli.Context {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	for name, value := range map[string]string{"GITLAB_TOKEN": "secret", "CI_JOB_TOKEN": ""} {
+		name := name
+		previous, set := os.LookupEnv(name)
+		os.Setenv(name, value)
+
+		t.Cleanup(func() {
+			if set {
+				os.Setenv(name, previous)
+			} else {
+				os.Unsetenv(name)
+			}
+		})
+	}
+
+	set := flag.NewFlagSet("status", flag.ContinueOnError)
+	set.String("host", server.URL, "")
+
+	return cli.NewContext(cli.NewApp(), set, nil)
+}
+
+func TestAuthStatus(t *testing.T) {
+	context := statusContext(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secret", r.Header.Get("PRIVATE-TOKEN"))
+
+		switch r.URL.Path {
+		case "/api/v4/user":
+			w.Write([]byte(`{"name":"Angel","username":"angel"}`))
+		case "/api/v4/personal_access_tokens/self":
+			w.Write([]byte(`{"scopes":["read_api","read_user"],"expires_at":"2030-01-01"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	var err error
+	output := captureStdout(t, func() { err = AuthStatus(context) })
+
+	assert.NoError(t, err)
+	assert.Contains(t, output, "Angel")
+	assert.Contains(t, output, "(angel)")
+	assert.Contains(t, output, "Profile: environment")
+	assert.Contains(t, output, "Token type: private-token")
+	assert.Contains(t, output, "Scopes: read_api, read_user")
+	assert.Contains(t, output, "Expires: 2030-01-01")
+	assert.Contains(t, output, "Token is valid")
+}
+
+func TestAuthStatusUnauthorized(t *testing.T) {
+	context := statusContext(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"message":"401 Unauthorized"}`))
+	})
+
+	var err error
+	output := captureStdout(t, func() { err = AuthStatus(context) })
+
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "is not valid")
+		assert.Equal(t, 1, err.(cli.ExitCoder).ExitCode())
+	}
+
+	assert.NotContains(t, output, "Token is valid")
+}
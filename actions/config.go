@@ -0,0 +1,40 @@
+package actions
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/urfave/cli/v2"
+	"gitlab.com/angel-afonso/gitlabcli/api"
+	"gitlab.com/angel-afonso/gitlabcli/auth"
+	"gitlab.com/angel-afonso/gitlabcli/config"
+	"gopkg.in/gookit/color.v1"
+)
+
+// Configure set up the http client shared by the api and auth requests
+// with the config file and the environment. It is the Before hook of the
+// commands that send requests, the local commands do not read the config
+func Configure(context *cli.Context) error {
+	cfg, err := config.Load()
+
+	if err != nil {
+		return err
+	}
+
+	transport, err := api.NewTransport(cfg)
+
+	if err != nil {
+		return err
+	}
+
+	if cfg.InsecureSkipVerify {
+		// stderr, the stdout of some commands is read by git
+		fmt.Fprintln(os.Stderr, color.Yellow.Sprint("TLS certificate verification is disabled"))
+	}
+
+	api.HTTPClient = &http.Client{Transport: transport}
+	auth.HTTPClient = api.HTTPClient
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gitlab.com/angel-afonso/gitlabcli/api"
+	"gitlab.com/angel-afonso/gitlabcli/auth"
+	"gitlab.com/angel-afonso/gitlabcli/utils"
+	"gopkg.in/gookit/color.v1"
+)
+
+func hint(message string) {
+	color.Yellow.Println(message)
+}
+
+// relogin offer to login again in the profile of the rejected session,
+// personal access tokens can not be renewed with the browser login
+func relogin(client *api.Client) {
+	session := client.Session()
+
+	if session == nil {
+		return
+	}
+
+	if session.Profile == "" {
+		hint("The token in GITLAB_TOKEN or CI_JOB_TOKEN is not valid")
+		return
+	}
+
+	if session.Type == auth.PrivateToken {
+		hint(fmt.Sprintf("The personal access token of %s is not valid, create a new one and login with gitlabcli --profile %s auth login --token <token>", session.Host, session.Profile))
+		return
+	}
+
+	if !utils.IsTerminal() {
+		hint(fmt.Sprintf("The session of %s is not valid, login again with gitlabcli --profile %s auth login", session.Host, session.Profile))
+		return
+	}
+
+	fmt.Printf("The session of %s is not valid, login again? ", session.Host)
+	color.Blue.Print("y/n ")
+	color.Gray.Print("default: n ")
+	color.Reset()
+
+	if choice := utils.ReadLine(); choice != "y" && choice != "yes" {
+		return
+	}
+
+	if _, err := auth.Login(auth.Options{Profile: session.Profile, Host: session.Host}); err != nil {
+		color.Red.Println(err.Error())
+		return
+	}
+
+	hint("Run the command again")
+}
+
+// HandleError print the error with a hint to solve it,
+// login is offered again when the session is not valid
+func HandleError(client *api.Client, err error) {
+	if errors.Is(err, context.Canceled) {
+		hint("Canceled")
+		return
+	}
+
+	color.Red.Println(err.Error())
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		hint("Gitlab did not answer in time, try again or increase --timeout")
+	case errors.Is(err, api.ErrUnauthorized):
+		relogin(client)
+	case errors.Is(err, api.ErrForbidden):
+		hint("You don't have permission for this action, check your role in the project")
+	case errors.Is(err, api.ErrNotFound):
+		hint("Check the project path and your permissions")
+	case errors.Is(err, api.ErrRateLimited):
+		hint("The gitlab rate limit was reached, wait a moment and try again")
+	case errors.Is(err, api.ErrServer):
+		hint("Gitlab is not available, try again later")
+	}
+}
@@ -0,0 +1,46 @@
+package actions
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/gookit/color"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/angel-afonso/gitlabcli/api"
+	"gitlab.com/angel-afonso/gitlabcli/auth"
+)
+
+// captureStdout returns what the function prints in the standard output,
+// including the output of github.com/gookit/color that keeps its own writer
+func captureStdout(t *testing.T, function func()) string {
+	reader, writer, err := os.Pipe()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout = writer
+	color.SetOutput(writer)
+
+	defer func() {
+		os.Stdout = stdout
+		color.ResetOutput()
+	}()
+
+	function()
+	writer.Close()
+
+	output, _ := ioutil.ReadAll(reader)
+	return string(output)
+}
+
+func TestReloginPrivateToken(t *testing.T) {
+	client := api.NewClient(&auth.Session{Profile: "work", Token: "expired", Type: auth.PrivateToken, Host: "gitlab.example.org"})
+
+	output := captureStdout(t, func() { relogin(&client) })
+
+	assert.Contains(t, output, "The personal access token of gitlab.example.org is not valid")
+	assert.Contains(t, output, "gitlabcli --profile work auth login --token <token>")
+}
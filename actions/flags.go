@@ -7,4 +7,14 @@ var (
 	Closed bool
 	// Merged store flag --merged value
 	Merged bool
+	// Token store flag --token value
+	Token string
+	// Device store flag --device value
+	Device bool
+	// All store flag --all value of the list commands
+	All bool
+	// LogoutAll store flag logout --all value
+	LogoutAll bool
+	// Limit store flag --limit value
+	Limit int
 )
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"fmt"
 
-	"github.com/eiannone/keyboard"
 	cli "github.com/urfave/cli/v2"
 	"gitlab.com/angel-afonso/gitlabcli/api"
 	"gitlab.com/angel-afonso/gitlabcli/utils"
@@ -34,16 +33,20 @@ func (il *baseIssue) Print() {
 	println()
 }
 
-func issueState() string {
-	if Opened {
-		return "opened"
+// issueState returns the state selected by the flags, nil lists every issue
+func issueState() *string {
+	var state string
+
+	switch {
+	case Opened:
+		state = "opened"
+	case Closed:
+		state = "closed"
+	default:
+		return nil
 	}
 
-	if Closed {
-		return "closed"
-	}
-
-	return "null"
+	return &state
 }
 
 // Issue struct representation
@@ -71,6 +74,33 @@ func (i *Issue) Print() {
 	}
 }
 
+// issuesQuery is the issues list query, paginated in Project.Issues
+type issuesQuery struct {
+	Project *struct {
+		Issues struct {
+			PageInfo api.PageInfo
+			Nodes    []baseIssue
+		} `graphql:"(first: $first, after: $after, state: $state)"`
+	} `graphql:"(fullPath:$path)"`
+}
+
+type issuesVariables struct {
+	path  string  `graphql-type:"ID!"`
+	state *string `graphql-type:"IssuableState"`
+}
+
+// issueQuery is the query of an issue by iid
+type issueQuery struct {
+	Project struct {
+		Issue *Issue `graphql:"(iid:$iid)"`
+	} `graphql:"(fullPath:$path)"`
+}
+
+type issueVariables struct {
+	path string `graphql-type:"ID!"`
+	iid  string
+}
+
 // IssuesList display a project's issues list
 func IssuesList(client *api.Client) func(*cli.Context) error {
 	return func(context *cli.Context) error {
@@ -81,74 +111,36 @@ func IssuesList(client *api.Client) func(*cli.Context) error {
 		}
 
 		spinner := utils.ShowSpinner()
+		defer spinner.Stop()
 
-		var query struct {
-			Project *struct {
-				Issues struct {
-					PageInfo struct {
-						EndCursor   string
-						HasNextPage bool
-					}
-					Nodes []baseIssue
-				} `graphql:"(first: 10, after: $after, state: $state)"`
-			} `graphql:"(fullPath:$path)"`
-		}
+		var query issuesQuery
 
-		variables := struct {
-			path  string `graphql-type:"ID!"`
-			after string
-			state string `graphql-type:"IssuableState"`
-		}{
+		variables := issuesVariables{
 			path:  path,
-			after: "",
 			state: issueState(),
 		}
 
-		if err := client.Query(&query, variables); err != nil {
-			return err
-		}
+		pages := paginate(context, client, &query, variables, "Project.Issues")
 
-		for {
+		for pages.Next() {
 			spinner.Stop()
 
 			if query.Project == nil {
-				return errors.New("An error has occurred, check the repository path and permissions")
+				return fmt.Errorf("Project %s not found, check the path and your permissions", path)
 			}
 
 			for _, issue := range query.Project.Issues.Nodes {
 				issue.Print()
 			}
 
-			if !query.Project.Issues.PageInfo.HasNextPage {
-				return nil
-			}
-
-			if err := keyboard.Open(); err != nil {
+			if next, err := morePages(pages); err != nil || !next {
 				return err
 			}
 
-			defer keyboard.Close()
-
-			for {
-				char, key, _ := keyboard.GetKey()
-
-				if key == keyboard.KeyEnter {
-					break
-				}
-
-				if char == 'q' || key == keyboard.KeyCtrlC || key == keyboard.KeyEsc {
-					println()
-					return nil
-				}
-			}
-
-			println()
-
-			variables.after = query.Project.Issues.PageInfo.EndCursor
 			spinner.Start()
-
-			client.Query(&query, variables)
 		}
+
+		return pages.Err()
 	}
 }
 
@@ -174,22 +166,16 @@ func ShowIssue(client *api.Client) func(*cli.Context) error {
 		}
 
 		spinner := utils.ShowSpinner()
+		defer spinner.Stop()
 
-		var query struct {
-			Project struct {
-				Issue *Issue `graphql:"(iid:$iid)"`
-			} `graphql:"(fullPath:$path)"`
-		}
+		var query issueQuery
 
-		variables := struct {
-			path string `graphql-type:"ID!"`
-			iid  string
-		}{
+		variables := issueVariables{
 			path,
 			iid,
 		}
 
-		if err := client.Query(&query, variables); err != nil {
+		if err := client.QueryContext(context.Context, &query, variables); err != nil {
 			return err
 		}
 
@@ -200,6 +186,6 @@ func ShowIssue(client *api.Client) func(*cli.Context) error {
 			return nil
 		}
 
-		return errors.New("An error has occurred, check the repository path and permissions")
+		return fmt.Errorf("Issue #%s not found in %s, check the path and your permissions", iid, path)
 	}
 }
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/eiannone/keyboard"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/gookit/color"
@@ -69,20 +68,83 @@ func (mr *MergeRequest) Print() {
 	println()
 }
 
-func mergeRequestState() string {
-	if Opened {
-		return "opened"
-	}
+// mergeRequestsQuery is the merge requests list query, paginated in Project.MergeRequests
+type mergeRequestsQuery struct {
+	Project *struct {
+		MergeRequests struct {
+			PageInfo api.PageInfo
+			Nodes    []baseMergeRequest
+		} `graphql:"(first: $first, after: $after, state: $state)"`
+	} `graphql:"(fullPath:$path)"`
+}
 
-	if Closed {
-		return "closed"
-	}
+type mergeRequestsVariables struct {
+	path  string  `graphql-type:"ID!"`
+	state *string `graphql-type:"MergeRequestState"`
+}
+
+// mergeRequestQuery is the query of a merge request by iid
+type mergeRequestQuery struct {
+	Project struct {
+		MergeRequest *MergeRequest `graphql:"(iid:$iid)"`
+	} `graphql:"(fullPath:$path)"`
+}
+
+type mergeRequestVariables struct {
+	path string `graphql-type:"ID!"`
+	iid  string `graphql-type:"String!"`
+}
+
+// createMergeRequestMutation creates a merge request and returns its iid
+type createMergeRequestMutation struct {
+	MergeRequestCreate struct {
+		MergeRequest struct {
+			Iid string
+		}
+		Errors []string
+	} `graphql:"(input:{title:$title,projectPath:$path,sourceBranch:$source,targetBranch:$target,description:$description})"`
+}
+
+type createMergeRequestVariables struct {
+	path        string `graphql-type:"ID!"`
+	title       string `graphql-type:"String!"`
+	source      string `graphql-type:"String!"`
+	target      string `graphql-type:"String!"`
+	description string
+}
+
+// assignMergeRequestMutation replaces the assignees of a merge request
+type assignMergeRequestMutation struct {
+	MergeRequestSetAssignees struct {
+		MergeRequest struct {
+			iid string
+		}
+		Errors []string
+	} `graphql:"(input:{projectPath:$path,iid:$iid,assigneeUsernames:$usernames})"`
+}
+
+type assignMergeRequestVariables struct {
+	path      string   `graphql-type:"ID!"`
+	iid       string   `graphql-type:"String!"`
+	usernames []string `graphql-type:"[String!]!"`
+}
 
-	if Merged {
-		return "merged"
+// mergeRequestState returns the state selected by the flags, nil lists every merge request
+func mergeRequestState() *string {
+	var state string
+
+	switch {
+	case Opened:
+		state = "opened"
+	case Closed:
+		state = "closed"
+	case Merged:
+		state = "merged"
+	default:
+		return nil
 	}
 
-	return "null"
+	return &state
 }
 
 // MergeRequestList display a paginated merge request for a given project by path
@@ -95,74 +157,36 @@ func MergeRequestList(client *api.Client) func(*cli.Context) error {
 		}
 
 		spinner := utils.ShowSpinner()
+		defer spinner.Stop()
 
-		var query struct {
-			Project *struct {
-				MergeRequests struct {
-					PageInfo struct {
-						EndCursor   string
-						HasNextPage bool
-					}
-					Nodes []baseMergeRequest
-				} `graphql:"(first: 10, after: $after, state: $state)"`
-			} `graphql:"(fullPath:$path)"`
-		}
+		var query mergeRequestsQuery
 
-		variables := struct {
-			path  string `graphql-type:"ID!"`
-			after string
-			state string `graphql-type:"MergeRequestState"`
-		}{
+		variables := mergeRequestsVariables{
 			path:  path,
-			after: "",
 			state: mergeRequestState(),
 		}
 
-		if err := client.Query(&query, variables); err != nil {
-			return err
-		}
+		pages := paginate(context, client, &query, variables, "Project.MergeRequests")
 
-		for {
+		for pages.Next() {
 			spinner.Stop()
 
 			if query.Project == nil {
-				return errors.New("An error has occurred, check the repository path and permissions")
+				return fmt.Errorf("Project %s not found, check the path and your permissions", path)
 			}
 
-			for _, issue := range query.Project.MergeRequests.Nodes {
-				issue.Print()
+			for _, mergeRequest := range query.Project.MergeRequests.Nodes {
+				mergeRequest.Print()
 			}
 
-			if !query.Project.MergeRequests.PageInfo.HasNextPage {
-				return nil
-			}
-
-			if err := keyboard.Open(); err != nil {
+			if next, err := morePages(pages); err != nil || !next {
 				return err
 			}
 
-			defer keyboard.Close()
-
-			for {
-				char, key, _ := keyboard.GetKey()
-
-				if key == keyboard.KeyEnter {
-					break
-				}
-
-				if char == 'q' || key == keyboard.KeyCtrlC || key == keyboard.KeyEsc {
-					println()
-					return nil
-				}
-			}
-
-			println()
-
-			variables.after = query.Project.MergeRequests.PageInfo.EndCursor
 			spinner.Start()
-
-			client.Query(&query, variables)
 		}
+
+		return pages.Err()
 	}
 }
 
@@ -188,22 +212,16 @@ func ShowMergeRequest(client *api.Client) func(*cli.Context) error {
 		}
 
 		spinner := utils.ShowSpinner()
+		defer spinner.Stop()
 
-		var query struct {
-			Project struct {
-				MergeRequest *MergeRequest `graphql:"(iid:$iid)"`
-			} `graphql:"(fullPath:$path)"`
-		}
+		var query mergeRequestQuery
 
-		variables := struct {
-			path string `graphql-type:"ID!"`
-			iid  string `graphql-type:"String!"`
-		}{
+		variables := mergeRequestVariables{
 			path,
 			iid,
 		}
 
-		if err := client.Query(&query, variables); err != nil {
+		if err := client.QueryContext(context.Context, &query, variables); err != nil {
 			return err
 		}
 
@@ -214,7 +232,7 @@ func ShowMergeRequest(client *api.Client) func(*cli.Context) error {
 			return nil
 		}
 
-		return errors.New("An error has occurred, check the repository path and permissions")
+		return fmt.Errorf("Merge request !%s not found in %s, check the path and your permissions", iid, path)
 	}
 }
 
@@ -222,6 +240,10 @@ func ShowMergeRequest(client *api.Client) func(*cli.Context) error {
 // by given project path
 func CreateMergeRequest(client *api.Client) func(*cli.Context) error {
 	return func(context *cli.Context) error {
+		if err := client.RequireScope(api.WriteScope); err != nil {
+			return err
+		}
+
 		path, err := utils.GetPathParam(context)
 		if err != nil {
 			return err
@@ -271,23 +293,11 @@ func CreateMergeRequest(client *api.Client) func(*cli.Context) error {
 		description := utils.ReadLine()
 
 		spinner := utils.ShowSpinner()
+		defer spinner.Stop()
 
-		var mutation struct {
-			MergeRequestCreate struct {
-				MergeRequest struct {
-					Iid string
-				}
-				Errors []string
-			} `graphql:"(input:{title:$title,projectPath:$path,sourceBranch:$source,targetBranch:$target,description:$description})"`
-		}
+		var mutation createMergeRequestMutation
 
-		variables := struct {
-			path        string `graphql-type:"ID!"`
-			title       string `graphql-type:"String!"`
-			source      string `graphql-type:"String!"`
-			target      string `graphql-type:"String!"`
-			description string
-		}{
+		variables := createMergeRequestVariables{
 			path,
 			title,
 			source,
@@ -295,11 +305,8 @@ func CreateMergeRequest(client *api.Client) func(*cli.Context) error {
 			description,
 		}
 
-		client.Mutation(&mutation, variables)
-
-		if len(mutation.MergeRequestCreate.Errors) > 0 {
-			color.Red.Println(strings.Join(mutation.MergeRequestCreate.Errors, "\n"))
-			return nil
+		if err := client.MutationContext(context.Context, &mutation, variables); err != nil {
+			return err
 		}
 
 		spinner.Stop()
@@ -314,22 +321,24 @@ func CreateMergeRequest(client *api.Client) func(*cli.Context) error {
 		if choice := utils.ReadLine(); choice == "y" || choice == "yes" {
 			spinner.Start()
 
-			users := getProjectMembers(client, path)
+			users, err := getProjectMembers(context, client, path)
+
+			if err != nil {
+				return err
+			}
 
 			spinner.Stop()
 
-			for index, user := range users {
-				color.Blue.Printf("%d ", index+1)
-				color.Reset()
-				fmt.Printf("%s (%s)\n", color.Bold.Sprint(user.Name), color.OpItalic.Sprint(user.Username))
-			}
+			user, err := chooseUser(users)
 
-			index := utils.ReadInt()
+			if err != nil {
+				return err
+			}
 
-			assignUserForMergeRequest(client,
+			return assignUserForMergeRequest(context, client,
 				mutation.MergeRequestCreate.MergeRequest.Iid,
 				path,
-				[]string{`"` + users[index-1].Username + `"`},
+				[]string{user.Username},
 			)
 		}
 
@@ -340,7 +349,12 @@ func CreateMergeRequest(client *api.Client) func(*cli.Context) error {
 // AssignMergeRequest interact with the graphql api to assign user to merge request
 func AssignMergeRequest(client *api.Client) func(*cli.Context) error {
 	return func(context *cli.Context) error {
+		if err := client.RequireScope(api.WriteScope); err != nil {
+			return err
+		}
+
 		spinner := utils.ShowSpinner()
+		defer spinner.Stop()
 
 		path, err := utils.GetPathParam(context)
 		if err != nil {
@@ -359,51 +373,69 @@ func AssignMergeRequest(client *api.Client) func(*cli.Context) error {
 			return errors.New("iid is required")
 		}
 
-		users := getProjectMembers(client, path)
+		users, err := getProjectMembers(context, client, path)
+
+		if err != nil {
+			return err
+		}
 
 		spinner.Stop()
 
-		for index, user := range users {
-			color.Blue.Printf("%d ", index+1)
-			color.Reset()
-			fmt.Printf("%s (%s)\n", color.Bold.Sprint(user.Name), color.OpItalic.Sprint(user.Username))
-		}
+		user, err := chooseUser(users)
 
-		index := utils.ReadInt()
+		if err != nil {
+			return err
+		}
 
-		assignUserForMergeRequest(client,
+		return assignUserForMergeRequest(context, client,
 			iid,
 			path,
-			[]string{`"` + users[index-1].Username + `"`},
+			[]string{user.Username},
 		)
-		return nil
 	}
 }
 
-func assignUserForMergeRequest(client *api.Client, iid string, path string, usernames []string) {
-	spinner := utils.ShowSpinner()
+// chooseUser display the numbered users and read the number of the selected one
+func chooseUser(users []User) (User, error) {
+	if len(users) == 0 {
+		return User{}, errors.New("The project has no members to assign")
+	}
 
-	var assignMutation struct {
-		MergeRequestSetAssignees struct {
-			MergeRequest struct {
-				iid string
-			}
-		} `graphql:"(input:{projectPath:$path,iid:$iid,assigneeUsernames:$usernames})"`
+	for index, user := range users {
+		color.Blue.Printf("%d ", index+1)
+		color.Reset()
+		fmt.Printf("%s (%s)\n", color.Bold.Sprint(user.Name), color.OpItalic.Sprint(user.Username))
+	}
+
+	for {
+		if index := utils.ReadInt(); index > 0 && index <= len(users) {
+			return users[index-1], nil
+		}
+
+		color.Red.Printf("Choose a number between 1 and %d\n", len(users))
+		color.Reset()
 	}
+}
 
-	assignVariables := struct {
-		path      string   `graphql-type:"ID!"`
-		iid       string   `graphql-type:"String!"`
-		usernames []string `graphql-type:"[String!]!"`
-	}{
+// assignUserForMergeRequest set the assignees of the merge request
+func assignUserForMergeRequest(context *cli.Context, client *api.Client, iid string, path string, usernames []string) error {
+	spinner := utils.ShowSpinner()
+	defer spinner.Stop()
+
+	var assignMutation assignMergeRequestMutation
+
+	assignVariables := assignMergeRequestVariables{
 		path:      path,
 		iid:       iid,
 		usernames: usernames,
 	}
 
-	client.Mutation(&assignMutation, assignVariables)
+	if err := client.MutationContext(context.Context, &assignMutation, assignVariables); err != nil {
+		return err
+	}
 
 	spinner.Stop()
 
-	color.LightGreen.Printf("%s assigned to merge request !%s\n", usernames[0], iid)
+	color.LightGreen.Printf("%s assigned to merge request !%s\n", strings.Join(usernames, ", "), iid)
+	return nil
 }
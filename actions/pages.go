@@ -0,0 +1,67 @@
+package actions
+
+import (
+	"github.com/eiannone/keyboard"
+	"github.com/urfave/cli/v2"
+	"gitlab.com/angel-afonso/gitlabcli/api"
+	"gitlab.com/angel-afonso/gitlabcli/utils"
+)
+
+// paginate returns a paginator of the list commands limited with the --limit flag
+func paginate(context *cli.Context, client *api.Client, query interface{}, variables interface{}, path string) *api.Paginator {
+	pages := client.Paginate(context.Context, query, variables, path)
+	pages.Limit = Limit
+	pages.PageSize = 100
+
+	if interactive() {
+		pages.PageSize = 10
+	}
+
+	return pages
+}
+
+// interactive returns true if the pages are displayed on demand
+func interactive() bool {
+	return !All && Limit == 0 && utils.IsTerminal()
+}
+
+// morePages returns true if the next page must be requested,
+// the user is asked when the pages are displayed on demand
+func morePages(pages *api.Paginator) (bool, error) {
+	if !pages.HasMore() {
+		return false, nil
+	}
+
+	if !interactive() {
+		return true, nil
+	}
+
+	return nextPage()
+}
+
+// nextPage wait for enter to display the next page, returns false when the user
+// quits with q, esc or ctrl-c. The keyboard is restored before returning
+func nextPage() (bool, error) {
+	if err := keyboard.Open(); err != nil {
+		return false, err
+	}
+
+	defer keyboard.Close()
+	defer println()
+
+	for {
+		char, key, err := keyboard.GetKey()
+
+		if err != nil {
+			return false, err
+		}
+
+		if key == keyboard.KeyEnter {
+			return true, nil
+		}
+
+		if char == 'q' || key == keyboard.KeyCtrlC || key == keyboard.KeyEsc {
+			return false, nil
+		}
+	}
+}
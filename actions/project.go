@@ -1,11 +1,9 @@
 package actions
 
 import (
-	"errors"
 	"fmt"
 	"strings"
 
-	"github.com/eiannone/keyboard"
 	"github.com/urfave/cli/v2"
 	"gitlab.com/angel-afonso/gitlabcli/api"
 	"gitlab.com/angel-afonso/gitlabcli/utils"
@@ -55,7 +53,23 @@ type Project struct {
 				Label string
 			}
 		}
-	} `graphql:"(first: 1, ref: \\\"master\\\")"`
+	} `graphql:"(first: 1, ref: \"master\")"`
+
+	// failures has the errors of the fields that could not be loaded
+	failures *api.GraphQLErrors `graphql:"-"`
+}
+
+// failed returns the message of the error of the field if it could not be loaded
+func (project *Project) failed(path string) (string, bool) {
+	if project.failures == nil {
+		return "", false
+	}
+
+	if failures := project.failures.Under(path); len(failures) > 0 {
+		return failures[0].Message, true
+	}
+
+	return "", false
 }
 
 // Print project data
@@ -67,13 +81,20 @@ func (project *Project) Print() {
 	println()
 	color.OpUnderscore.Printf("Stars: %d Forks: %d\n", project.StarCount, project.ForksCount)
 
-	if len(project.Pipelines.Nodes) > 0 {
+	if message, failed := project.failed("project.pipelines"); failed {
+		println()
+		color.Yellow.Printf("Pipeline status: unavailable, %s\n", message)
+		println()
+	} else if len(project.Pipelines.Nodes) > 0 {
 		println()
 		color.OpItalic.Printf("Pipeline status: %s \n", color.Bold.Sprint(project.Pipelines.Nodes[0].DetailedStatus.Label))
 		println()
 	}
 
-	if len(project.Releases.Nodes) > 0 {
+	if message, failed := project.failed("project.releases"); failed {
+		color.Yellow.Printf("Last Release: unavailable, %s\n", message)
+		println()
+	} else if len(project.Releases.Nodes) > 0 {
 		color.OpItalic.Printf("Last Release: %s \n", project.Releases.Nodes[0].Name)
 		println()
 	}
@@ -86,68 +107,49 @@ func (project *Project) Print() {
 	color.OpItalic.Println(color.Gray.Sprint(project.WebURL))
 }
 
+// projectsQuery is the query of the user's projects, paginated in Projects
+type projectsQuery struct {
+	Projects struct {
+		PageInfo api.PageInfo
+		Nodes    []baseProject
+	} `graphql:"(membership: true, first: $first, after: $after)"`
+}
+
+// projectQuery is the query of a project by path
+type projectQuery struct {
+	Project *Project `graphql:"(fullPath:$path)"`
+}
+
+type projectVariables struct {
+	path string `graphql-type:"ID!"`
+}
+
 // ProjectList send request to get user's project
 // and print a table with projects
 func ProjectList(client *api.Client) func(*cli.Context) error {
 	return func(context *cli.Context) error {
 		spinner := utils.ShowSpinner()
+		defer spinner.Stop()
 
-		var query struct {
-			Projects struct {
-				PageInfo struct {
-					EndCursor   string
-					HasNextPage bool
-				}
-				Nodes []baseProject
-			} `graphql:"(membership: true, first: 10,after: $after)"`
-		}
+		var query projectsQuery
 
-		variables := struct {
-			after string
-		}{
-			after: "",
-		}
+		pages := paginate(context, client, &query, nil, "Projects")
 
-		if err := client.Query(&query, variables); err != nil {
-			return err
-		}
-
-		for {
+		for pages.Next() {
 			spinner.Stop()
+
 			for _, project := range query.Projects.Nodes {
 				project.Print()
 			}
 
-			if !query.Projects.PageInfo.HasNextPage {
-				return nil
-			}
-
-			if err := keyboard.Open(); err != nil {
+			if next, err := morePages(pages); err != nil || !next {
 				return err
 			}
 
-			defer keyboard.Close()
-
-			for {
-				char, key, _ := keyboard.GetKey()
-
-				if key == keyboard.KeyEnter {
-					break
-				}
-
-				if char == 'q' || key == keyboard.KeyCtrlC || key == keyboard.KeyEsc {
-					println()
-					return nil
-				}
-			}
-
-			println()
-
-			variables.after = query.Projects.PageInfo.EndCursor
 			spinner.Start()
-
-			client.Query(&query, variables)
 		}
+
+		return pages.Err()
 	}
 }
 
@@ -161,29 +163,29 @@ func ProjectView(client *api.Client) func(*cli.Context) error {
 		}
 
 		spinner := utils.ShowSpinner()
+		defer spinner.Stop()
 
-		var query struct {
-			Project *Project `graphql:"(fullPath:$path)"`
-		}
+		var query projectQuery
 
-		variables := struct {
-			path string `graphql-type:"ID!"`
-		}{
+		variables := projectVariables{
 			path,
 		}
 
-		if err := client.Query(&query, variables); err != nil {
+		failures, err := client.QueryPartial(context.Context, &query, variables)
+
+		if err != nil {
 			return err
 		}
 
 		spinner.Stop()
 
 		if query.Project != nil {
+			query.Project.failures = failures
 			query.Project.Print()
 			return nil
 		}
 
-		return errors.New("An error has occurred, check the repository path and permissions")
+		return fmt.Errorf("Project %s not found, check the path and your permissions", path)
 	}
 }
 
@@ -197,8 +199,13 @@ func ProjectMembers(client *api.Client) func(*cli.Context) error {
 		}
 
 		spinner := utils.ShowSpinner()
+		defer spinner.Stop()
 
-		users := getProjectMembers(client, path)
+		users, err := getProjectMembers(context, client, path)
+
+		if err != nil {
+			return err
+		}
 
 		spinner.Stop()
 		for _, user := range users {
@@ -211,9 +218,10 @@ func ProjectMembers(client *api.Client) func(*cli.Context) error {
 	}
 }
 
-func getProjectMembers(client *api.Client, path string) []User {
+// getProjectMembers request every page of the project users
+func getProjectMembers(context *cli.Context, client *api.Client, path string) ([]User, error) {
 	var users []User
 
-	client.Get(fmt.Sprintf("projects/%s/users", strings.ReplaceAll(path, "/", "%2F")), &users)
-	return users
+	err := client.GetAll(context.Context, fmt.Sprintf("projects/%s/users", strings.ReplaceAll(path, "/", "%2F")), &users)
+	return users, err
 }
@@ -0,0 +1,56 @@
+package actions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/angel-afonso/gitlabcli/api"
+)
+
+// TestQueriesMatchSchema check every query and mutation of the commands against
+// the vendored schema, refresh it with `gitlabcli schema refresh`
+func TestQueriesMatchSchema(t *testing.T) {
+	schema, err := api.LoadSchema("../api/schema.json")
+
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	queries := map[string]struct {
+		query interface{}
+		vars  interface{}
+	}{
+		"project view": {&projectQuery{}, projectVariables{}},
+		"issue view":   {&issueQuery{}, issueVariables{}},
+		"mr view":      {&mergeRequestQuery{}, mergeRequestVariables{}},
+	}
+
+	for name, query := range queries {
+		assert.NoError(t, schema.ValidateQuery(query.query, query.vars), name)
+	}
+
+	paginated := map[string]struct {
+		query interface{}
+		vars  interface{}
+	}{
+		"project list": {&projectsQuery{}, nil},
+		"issue list":   {&issuesQuery{}, issuesVariables{}},
+		"mr list":      {&mergeRequestsQuery{}, mergeRequestsVariables{}},
+	}
+
+	for name, query := range paginated {
+		assert.NoError(t, schema.ValidatePaginated(query.query, query.vars), name)
+	}
+
+	mutations := map[string]struct {
+		mutation interface{}
+		vars     interface{}
+	}{
+		"mr create": {&createMergeRequestMutation{}, createMergeRequestVariables{}},
+		"mr assign": {&assignMergeRequestMutation{}, assignMergeRequestVariables{}},
+	}
+
+	for name, mutation := range mutations {
+		assert.NoError(t, schema.ValidateMutation(mutation.mutation, mutation.vars), name)
+	}
+}
@@ -0,0 +1,43 @@
+package actions
+
+import (
+	"io/ioutil"
+
+	"github.com/gookit/color"
+	cli "github.com/urfave/cli/v2"
+	"gitlab.com/angel-afonso/gitlabcli/api"
+	"gitlab.com/angel-afonso/gitlabcli/utils"
+)
+
+// defaultSchemaPath is the vendored schema, relative to the repository root
+const defaultSchemaPath = "api/schema.json"
+
+// RefreshSchema download the graphql schema of the instance to the file
+// checked by the query tests
+func RefreshSchema(client *api.Client) func(*cli.Context) error {
+	return func(context *cli.Context) error {
+		path := defaultSchemaPath
+
+		if context.Args().Len() > 0 {
+			path = context.Args().Get(0)
+		}
+
+		spinner := utils.ShowSpinner()
+		defer spinner.Stop()
+
+		schema, err := client.Introspect(context.Context)
+
+		if err != nil {
+			return err
+		}
+
+		if err := ioutil.WriteFile(path, schema, 0644); err != nil {
+			return err
+		}
+
+		spinner.Stop()
+
+		color.LightGreen.Printf("Schema of %s written to %s\n", client.Session().Host, path)
+		return nil
+	}
+}
@@ -98,7 +98,7 @@ func TestFormatMutationWithArrayVar(t *testing.T) {
 		Usernames []string `graphql-type:"[String!]!"`
 	}{
 		Title:     "asd",
-		Usernames: []string{`"asd"`},
+		Usernames: []string{"asd"},
 	}
 	q := formatMutation(query, vars)
 
@@ -126,3 +126,246 @@ func TestQueryWithVariables(t *testing.T) {
 
 	assert.Equal(t, `{"query":"query($field:Int,$foo:String,$baz:ID!,){projects(membership: true){nodes{name,}}}","variables":{"field":123,"foo":"asd","baz":123}}`, q)
 }
+
+func TestFormatVariablesEscaping(t *testing.T) {
+	_, variables := formatVariables(struct {
+		description string
+		title       string
+		state       *string
+	}{
+		description: "line \"one\"\nC:\\path <b>",
+		title:       "null",
+	})
+
+	assert.Equal(t, `"variables":{"description":"line \"one\"\nC:\\path <b>","title":"null","state":null}`, variables)
+}
+
+func TestFormatVariablesInputObjects(t *testing.T) {
+	type LabelInput struct {
+		Title string
+		Color *string
+	}
+
+	type IssueInput struct {
+		Title  string
+		Labels []LabelInput
+		Weight *int
+	}
+
+	weight := 3
+
+	queryVars, variables := formatVariables(struct {
+		input   IssueInput
+		labels  []LabelInput
+		due     *string
+		weight  *int
+		options map[string]bool `graphql-type:"JSON"`
+	}{
+		input: IssueInput{
+			Title:  "bug",
+			Labels: []LabelInput{{Title: "p1"}},
+			Weight: &weight,
+		},
+		labels:  []LabelInput{},
+		weight:  &weight,
+		options: map[string]bool{"confidential": true},
+	})
+
+	assert.Equal(t, "$input:IssueInput,$labels:[LabelInput],$due:String,$weight:Int,$options:JSON,", queryVars)
+	assert.Equal(t, `"variables":{"input":{"labels":[{"color":null,"title":"p1"}],"title":"bug","weight":3},"labels":[],"due":null,"weight":3,"options":{"confidential":true}}`, variables)
+}
+
+func TestFormatQueryWithAliases(t *testing.T) {
+	var query struct {
+		Project struct {
+			Opened struct {
+				Count int
+			} `graphql-bind:"mergeRequests" graphql:"(state: opened)"`
+			Merged struct {
+				Count int
+			} `graphql-bind:"mergeRequests" graphql:"(state: merged)"`
+			WebURL string `graphql-bind:"webUrl"`
+		} `graphql:"(fullPath: $path)"`
+	}
+
+	assert.Equal(t,
+		"project(fullPath: $path){opened:mergeRequests(state: opened){count,}merged:mergeRequests(state: merged){count,}webUrl,}",
+		generateQueryBody(&query))
+
+	assert.NoError(t, bindGraphqlResponse([]byte(`{"data":{"project":{"opened":{"count":3},"merged":{"count":7},"webUrl":"https://gitlab.com/a/b"}}}`), &query))
+	assert.Equal(t, 3, query.Project.Opened.Count)
+	assert.Equal(t, 7, query.Project.Merged.Count)
+	assert.Equal(t, "https://gitlab.com/a/b", query.Project.WebURL)
+}
+
+type mergeRequestFields struct {
+	Title        string
+	SourceBranch string
+}
+
+func TestFormatQueryWithFragments(t *testing.T) {
+	type issueTarget struct {
+		Title string
+		Iid   string
+	}
+
+	var query struct {
+		CurrentUser struct {
+			Todos struct {
+				Nodes []struct {
+					Target struct {
+						Issue        *issueTarget        `graphql:"... on Issue"`
+						MergeRequest *mergeRequestFields `graphql:"fragment on MergeRequest"`
+					}
+				}
+			}
+			AssignedMergeRequests struct {
+				Nodes []struct {
+					mergeRequestFields `graphql:"fragment on MergeRequest"`
+				}
+			}
+		}
+	}
+
+	assert.Equal(t,
+		`{"query":"{currentUser{todos{nodes{target{... on Issue{_onIssue:__typename,title,iid,}...mergeRequestFields,}}}assignedMergeRequests{nodes{...mergeRequestFields,}}}}fragment mergeRequestFields on MergeRequest{_onMergeRequest:__typename,title,sourceBranch,}","variables":{}}`,
+		formatQuery(&query, nil))
+
+	assert.NoError(t, bindGraphqlResponse([]byte(`{"data":{"currentUser":{
+		"todos":{"nodes":[
+			{"target":{"_onIssue":"Issue","title":"bug","iid":"4"}},
+			{"target":{"_onMergeRequest":"MergeRequest","title":"fix","sourceBranch":"fix-bug"}}
+		]},
+		"assignedMergeRequests":{"nodes":[{"_onMergeRequest":"MergeRequest","title":"feature","sourceBranch":"feature"}]}
+	}}}`), &query))
+
+	todos := query.CurrentUser.Todos.Nodes
+	assert.Equal(t, &issueTarget{Title: "bug", Iid: "4"}, todos[0].Target.Issue)
+	assert.Nil(t, todos[0].Target.MergeRequest)
+	assert.Nil(t, todos[1].Target.Issue)
+	assert.Equal(t, &mergeRequestFields{Title: "fix", SourceBranch: "fix-bug"}, todos[1].Target.MergeRequest)
+	assert.Equal(t, "feature", query.CurrentUser.AssignedMergeRequests.Nodes[0].SourceBranch)
+}
+
+func TestFragmentsOnInterfaces(t *testing.T) {
+	type noteable struct {
+		WebURL string `graphql-bind:"webUrl"`
+	}
+
+	var query struct {
+		CurrentUser struct {
+			Todos struct {
+				Nodes []struct {
+					Target struct {
+						Noteable *noteable `graphql:"... on Noteable"`
+						Issue    *struct {
+							Iid string
+						} `graphql:"... on Issue"`
+					}
+				}
+			}
+		}
+	}
+
+	assert.Equal(t,
+		"currentUser{todos{nodes{target{... on Noteable{_onNoteable:__typename,webUrl,}... on Issue{_onIssue:__typename,iid,}}}}}",
+		generateQueryBody(&query))
+
+	// the __typename of the objects is never the interface,
+	// the markers tell which fragments applied
+	assert.NoError(t, bindGraphqlResponse([]byte(`{"data":{"currentUser":{"todos":{"nodes":[
+		{"target":{"_onNoteable":"Issue","_onIssue":"Issue","webUrl":"https://gitlab.com/a/b/-/issues/4","iid":"4"}},
+		{"target":{"_onNoteable":"MergeRequest","webUrl":"https://gitlab.com/a/b/-/merge_requests/7"}},
+		{"target":{}}
+	]}}}}`), &query))
+
+	todos := query.CurrentUser.Todos.Nodes
+	assert.Equal(t, "https://gitlab.com/a/b/-/issues/4", todos[0].Target.Noteable.WebURL)
+	assert.Equal(t, "4", todos[0].Target.Issue.Iid)
+	assert.Equal(t, "https://gitlab.com/a/b/-/merge_requests/7", todos[1].Target.Noteable.WebURL)
+	assert.Nil(t, todos[1].Target.Issue)
+	assert.Nil(t, todos[2].Target.Noteable)
+	assert.Nil(t, todos[2].Target.Issue)
+}
+
+func TestNamedFragmentsOfSameName(t *testing.T) {
+	type user struct {
+		Username string
+	}
+
+	type author = user
+
+	{
+		// same name as the type of the outer scope, like types of two packages
+		type user struct {
+			Name string
+		}
+
+		var query struct {
+			Issue struct {
+				Author struct {
+					author `graphql:"fragment on User"`
+				}
+				Assignees struct {
+					Nodes []struct {
+						user `graphql:"fragment on User"`
+					}
+				}
+				Participants struct {
+					Nodes []struct {
+						author `graphql:"fragment on User"`
+					}
+				}
+			}
+		}
+
+		assert.Equal(t,
+			`{"query":"{issue{author{...user,}assignees{nodes{...user2,}}participants{nodes{...user,}}}}fragment user on User{_onUser:__typename,username,}fragment user2 on User{_onUser:__typename,name,}","variables":{}}`,
+			formatQuery(&query, nil))
+
+		assert.NoError(t, bindGraphqlResponse([]byte(`{"data":{"issue":{
+			"author":{"_onUser":"User","username":"angel"},
+			"assignees":{"nodes":[{"_onUser":"User","name":"Angel"}]},
+			"participants":{"nodes":[{"_onUser":"User","username":"afonso"}]}
+		}}}`), &query))
+
+		assert.Equal(t, "angel", query.Issue.Author.Username)
+		assert.Equal(t, "Angel", query.Issue.Assignees.Nodes[0].Name)
+		assert.Equal(t, "afonso", query.Issue.Participants.Nodes[0].Username)
+	}
+}
+
+func TestNamedFragmentOfAnonymousStruct(t *testing.T) {
+	var query struct {
+		Project struct {
+			Owner struct {
+				Name string
+			} `graphql:"fragment on User"`
+		}
+	}
+
+	assert.PanicsWithValue(t, `the fragment on User needs a named struct type, use "... on User" for anonymous structs`, func() {
+		formatQuery(&query, nil)
+	})
+}
+
+func TestBindComposition(t *testing.T) {
+	type composition struct {
+		Name     string
+		Lastname string
+	}
+
+	var query struct {
+		Users struct {
+			Nodes []struct {
+				composition `graphql:"inner"`
+				Age         int
+				ignored     string
+			}
+		}
+	}
+
+	assert.NoError(t, bindGraphqlResponse([]byte(`{"data":{"users":{"nodes":[{"name":"Ada","lastname":"Lovelace","age":36}]}}}`), &query))
+	assert.Equal(t, "Lovelace", query.Users.Nodes[0].Lastname)
+	assert.Equal(t, 36, query.Users.Nodes[0].Age)
+}
@@ -1,59 +1,146 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math/rand"
+	"net"
 	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"time"
 
 	"gitlab.com/angel-afonso/gitlabcli/auth"
 )
 
 const (
-	url     = "https://gitlab.com/api/graphql"
-	graphql = "https://gitlab.com/api/graphql"
-	rest    = "https://gitlab.com/api/v4"
+	graphql = "api/graphql"
+	rest    = "api/v4"
 
 	get  = "GET"
 	post = "POST"
+
+	// DefaultRetries is the number of times a failed idempotent request is retried
+	DefaultRetries = 3
+
+	// retryBase is the first back-off delay, doubled on each retry
+	retryBase = 500 * time.Millisecond
+	// maxRetryWait is the longest wait before a retry, requests that
+	// must wait longer fail without retry
+	maxRetryWait = time.Minute
 )
 
+// sleep waits before a retry until the context is done, replaced in tests
+var sleep = func(ctx context.Context, wait time.Duration) error {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // Client graphql client
 type Client struct {
-	session *auth.Session
+	session         *auth.Session
+	scopesRequested bool
+
+	// Retries is the number of times a failed idempotent request is retried
+	Retries int
+	// Timeout limits each request attempt, zero means no limit
+	Timeout time.Duration
+	// Debug receives the debug log with a summary of every request, nil disables it
+	Debug io.Writer
+	// Trace records every request and response, nil disables it
+	Trace *Trace
 }
 
+// ErrJobTokenNotAllowed is returned for requests that the ci job token can not authorize
+var ErrJobTokenNotAllowed = errors.New("the CI job token can not be used for this request, set GITLAB_TOKEN with a personal access token")
+
+// jobTokenEndpoints are the rest endpoints that accept the ci job token
+var jobTokenEndpoints = regexp.MustCompile(`^(job|projects/[^/]+/(packages|releases|jobs|deployments|environments|secure_files|terraform/state|trigger/pipeline))([/?].*)?$`)
+
 type wrapper struct {
-	Data   interface{}
-	Errors []struct {
-		Message string
-	}
+	Data   json.RawMessage
+	Errors []GraphQLError
 }
 
 // NewClient create new graphql client
 func NewClient(session *auth.Session) Client {
-	return Client{session}
+	return Client{session: session, Retries: DefaultRetries}
 }
 
+// Session returns the session used to authorize the requests
+func (c *Client) Session() *auth.Session {
+	return c.session
+}
+
+// debugf write a line in the debug log, if it is enabled
+func (c *Client) debugf(format string, args ...interface{}) {
+	if c.Debug != nil {
+		fmt.Fprintf(c.Debug, format+"\n", args...)
+	}
+}
+
+// endpoint returns the absolute url of the given api path
+func (c *Client) endpoint(path string) string {
+	return fmt.Sprintf("%s/%s", c.session.BaseURL(), path)
+}
+
+// bindGraphqlResponse bind the data of the response, the errors are returned
+// as GraphQLErrors after binding the data of the fields that did not fail
 func bindGraphqlResponse(body []byte, bind interface{}) error {
-	response := wrapper{Data: bind}
+	var response wrapper
 	err := json.Unmarshal(body, &response)
 
 	if err != nil {
 		return err
 	}
 
-	if len(response.Errors) > 0 {
-		for _, err := range response.Errors {
-			return errors.New(err.Message)
+	if !isNull(response.Data) && bind != nil {
+		if err := decode(response.Data, reflect.ValueOf(bind)); err != nil {
+			return err
 		}
 	}
 
+	if len(response.Errors) > 0 {
+		return &GraphQLErrors{Errors: response.Errors, Partial: hasData(response.Data)}
+	}
+
 	return nil
 }
 
+// hasData returns true if a field of the response data is not null
+func hasData(data json.RawMessage) bool {
+	var fields map[string]json.RawMessage
+
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return false
+	}
+
+	for _, value := range fields {
+		if !isNull(value) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func bindRestResponse(body []byte, bind interface{}) error {
+	if len(body) == 0 {
+		return nil
+	}
+
 	response := bind
 	err := json.Unmarshal(body, &response)
 
@@ -64,23 +151,207 @@ func bindRestResponse(body []byte, bind interface{}) error {
 	return nil
 }
 
-func (c *Client) send(req *http.Request) ([]byte, error) {
-	req.Header.Set("Authorization", fmt.Sprintf("%s %s", c.session.Type, c.session.Token))
+// allowed check that the session can authorize a request to the given rest path,
+// graphql requests are identified by an empty path
+func (c *Client) allowed(path string) error {
+	if c.session.Type == auth.JobToken && (path == "" || !jobTokenEndpoints.MatchString(path)) {
+		return ErrJobTokenNotAllowed
+	}
+	return nil
+}
+
+// authorize set the credentials header for the session token type
+func (c *Client) authorize(req *http.Request) {
+	switch c.session.Type {
+	case auth.PrivateToken:
+		req.Header.Set("PRIVATE-TOKEN", c.session.Token)
+	case auth.JobToken:
+		req.Header.Set("JOB-TOKEN", c.session.Token)
+	default:
+		req.Header.Set("Authorization", fmt.Sprintf("%s %s", c.session.Type, c.session.Token))
+	}
+}
+
+// do send the request with the session credentials
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	c.authorize(req)
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	return HTTPClient.Do(req)
+}
 
-	if err != nil {
-		return nil, err
+// roundTrip send the request and read the response body, the exchange
+// is written in the debug log and the trace when they are enabled
+func (c *Client) roundTrip(req *http.Request) (*http.Response, []byte, error) {
+	ex := &exchange{request: req, started: time.Now()}
+
+	if c.tracing() {
+		ex.requestBody = requestBody(req)
+	}
+
+	resp, err := c.do(req)
+
+	if err == nil {
+		defer resp.Body.Close()
+		ex.body, err = ioutil.ReadAll(resp.Body)
+	}
+
+	if c.tracing() {
+		ex.response, ex.err, ex.elapsed = resp, err, time.Since(ex.started)
+		c.trace(ex)
+	}
+
+	return resp, ex.body, err
+}
+
+// send the request and returns the response body and headers, idempotent requests
+// are retried on network errors, rate limits and unavailable servers
+func (c *Client) send(req *http.Request, idempotent bool) ([]byte, http.Header, error) {
+	retries := 0
+
+	if idempotent {
+		retries = c.Retries
+	}
+
+	for attempt := 0; ; attempt++ {
+		body, header, err := c.sendOnce(req)
+
+		if err == nil || attempt >= retries || req.Context().Err() != nil {
+			return body, header, err
+		}
+
+		wait, ok := retryDelay(err, attempt)
+
+		if !ok {
+			return nil, nil, err
+		}
+
+		c.debugf("%s %s failed: %s, retry %d of %d in %s", req.Method, req.URL.Path, err.Error(), attempt+1, retries, wait.Round(time.Millisecond))
+
+		if err := sleep(req.Context(), wait); err != nil {
+			return nil, nil, err
+		}
+
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+}
+
+// retryDelay returns the time to wait before retrying the failed request,
+// false if the error is not temporary
+func retryDelay(err error, attempt int) (time.Duration, bool) {
+	var responseErr *ResponseError
+
+	if errors.As(err, &responseErr) {
+		switch responseErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		default:
+			return 0, false
+		}
+
+		if responseErr.RetryAfter > 0 {
+			return responseErr.RetryAfter, responseErr.RetryAfter <= maxRetryWait
+		}
+
+		return backoff(attempt), true
+	}
+
+	var netErr net.Error
+
+	if errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return backoff(attempt), true
+	}
+
+	return 0, false
+}
+
+// backoff returns the exponential delay of the given attempt,
+// with a random jitter of up to half the delay
+func backoff(attempt int) time.Duration {
+	delay := retryBase << uint(attempt)
+
+	if delay > maxRetryWait/2 || delay <= 0 {
+		delay = maxRetryWait / 2
+	}
+
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// retryAfter returns the delay requested by the Retry-After header, in seconds
+// or as http date, or by the RateLimit-Reset unix time. Zero if none is set
+func retryAfter(header http.Header) time.Duration {
+	if value := header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+
+		if date, err := http.ParseTime(value); err == nil {
+			return time.Until(date)
+		}
+	}
+
+	if value := header.Get("RateLimit-Reset"); value != "" {
+		if reset, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return time.Until(time.Unix(reset, 0))
+		}
 	}
 
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
+	return 0
+}
+
+// sendOnce send the request refreshing the oauth token when it is expired
+// or rejected, and returns the response body and headers
+func (c *Client) sendOnce(req *http.Request) ([]byte, http.Header, error) {
+	if c.Timeout > 0 {
+		ctx, cancel := context.WithTimeout(req.Context(), c.Timeout)
+		defer cancel()
+		req = req.WithContext(ctx)
+	}
+
+	if c.session.Expired() {
+		if err := auth.Refresh(c.session); err != nil {
+			return nil, nil, refreshError(req, err)
+		}
+	}
+
+	resp, body, err := c.roundTrip(req)
 
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return body, nil
+	if resp.StatusCode == http.StatusUnauthorized && c.session.RefreshToken != "" && req.GetBody != nil {
+		if err := auth.Refresh(c.session); err != nil {
+			return nil, nil, refreshError(req, err)
+		}
+
+		if req.Body, err = req.GetBody(); err != nil {
+			return nil, nil, err
+		}
+
+		if resp, body, err = c.roundTrip(req); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		responseErr := newResponseError(req, resp.StatusCode, body)
+		responseErr.RetryAfter = retryAfter(resp.Header)
+		return nil, nil, responseErr
+	}
+
+	return body, resp.Header, nil
+}
+
+// refreshError returns an unauthorized error for sessions that can not be refreshed
+func refreshError(req *http.Request, err error) error {
+	return &ResponseError{
+		StatusCode: http.StatusUnauthorized,
+		Method:     req.Method,
+		Path:       req.URL.Path,
+		Message:    fmt.Sprintf("the session expired and could not be refreshed: %s", err.Error()),
+	}
 }
@@ -0,0 +1,379 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/angel-afonso/gitlabcli/auth"
+)
+
+func TestPrivateTokenHeader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secret", r.Header.Get("PRIVATE-TOKEN"))
+		assert.Empty(t, r.Header.Get("Authorization"))
+		assert.Equal(t, "/api/v4/user", r.URL.Path)
+		w.Write([]byte(`{"username":"root"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(&auth.Session{Token: "secret", Type: auth.PrivateToken, Host: server.URL})
+
+	var user struct {
+		Username string
+	}
+
+	assert.NoError(t, client.Get("user", &user))
+	assert.Equal(t, "root", user.Username)
+}
+
+func TestJobTokenEndpoints(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "job", r.Header.Get("JOB-TOKEN"))
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	client := NewClient(&auth.Session{Token: "job", Type: auth.JobToken, Host: server.URL})
+
+	var releases []struct{ Name string }
+
+	assert.NoError(t, client.Get("projects/group%2Fproject/releases", &releases))
+	assert.Equal(t, ErrJobTokenNotAllowed, client.Get("projects/group%2Fproject/users", &releases))
+
+	var query struct {
+		CurrentUser struct {
+			Username string
+		}
+	}
+
+	assert.Equal(t, ErrJobTokenNotAllowed, client.Query(&query, nil))
+}
+
+func TestRefreshOnUnauthorized(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/oauth/token":
+			r.ParseForm()
+			assert.Equal(t, "refresh_token", r.PostForm.Get("grant_type"))
+			assert.Equal(t, "old-refresh", r.PostForm.Get("refresh_token"))
+			w.Write([]byte(`{"access_token":"new","token_type":"Bearer","refresh_token":"new-refresh","expires_in":7200}`))
+		case "/api/v4/user":
+			if r.Header.Get("Authorization") != "Bearer new" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"username":"root"}`))
+		}
+	}))
+	defer server.Close()
+
+	session := &auth.Session{Token: "old", Type: "Bearer", Host: server.URL, RefreshToken: "old-refresh"}
+	client := NewClient(session)
+
+	var user struct {
+		Username string
+	}
+
+	assert.NoError(t, client.Get("user", &user))
+	assert.Equal(t, "root", user.Username)
+	assert.Equal(t, "new", session.Token)
+	assert.Equal(t, "new-refresh", session.RefreshToken)
+	assert.False(t, session.Expired())
+}
+
+func TestMutationRequiresWriteScope(t *testing.T) {
+	requests := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.Equal(t, "/api/v4/personal_access_tokens/self", r.URL.Path)
+		w.Write([]byte(`{"scopes":["read_api"]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(&auth.Session{Token: "secret", Type: auth.PrivateToken, Host: server.URL})
+
+	var mutation struct {
+		MergeRequestCreate struct {
+			Errors []string
+		}
+	}
+
+	err := client.Mutation(&mutation, nil)
+
+	assert.Equal(t, &ScopeError{Scope: WriteScope}, err)
+	assert.Equal(t, []string{"read_api"}, client.Scopes())
+	assert.Equal(t, 1, requests)
+}
+
+func TestResponseError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v4/projects/missing":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"404 Project Not Found"}`))
+		case "/api/v4/projects":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"message":{"name":["is too long"],"path":["has already been taken"]}}`))
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte(`<html>Bad Gateway</html>`))
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(&auth.Session{Token: "secret", Type: auth.PrivateToken, Host: server.URL})
+	client.Retries = 0
+
+	err := client.Get("projects/missing", nil)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.Equal(t, "GET /api/v4/projects/missing: 404 Not Found: 404 Project Not Found", err.Error())
+
+	var responseErr *ResponseError
+	err = client.Post("projects", []byte(`{"name":"project"}`), nil)
+	assert.True(t, errors.As(err, &responseErr))
+	assert.Equal(t, http.StatusBadRequest, responseErr.StatusCode)
+	assert.Equal(t, "name [is too long], path [has already been taken]", responseErr.Message)
+
+	err = client.Get("user", nil)
+	assert.True(t, errors.Is(err, ErrServer))
+	assert.Equal(t, "GET /api/v4/user: 502 Bad Gateway", err.Error())
+}
+
+func TestRetry(t *testing.T) {
+	var waits []time.Duration
+
+	defer func(original func(context.Context, time.Duration) error) { sleep = original }(sleep)
+
+	sleep = func(ctx context.Context, wait time.Duration) error {
+		waits = append(waits, wait)
+		return nil
+	}
+
+	requests := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		switch requests {
+		case 1:
+			w.Header().Set("Retry-After", "2")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			body, _ := ioutil.ReadAll(r.Body)
+			assert.Contains(t, string(body), "currentUser")
+			w.Write([]byte(`{"data":{"currentUser":{"username":"root"}}}`))
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(&auth.Session{Token: "secret", Type: auth.PrivateToken, Host: server.URL})
+
+	var debug bytes.Buffer
+	client.Debug = &debug
+
+	var query struct {
+		CurrentUser struct {
+			Username string
+		}
+	}
+
+	assert.NoError(t, client.Query(&query, nil))
+	assert.Equal(t, "root", query.CurrentUser.Username)
+	assert.Equal(t, 3, requests)
+	assert.Len(t, waits, 2)
+	assert.Equal(t, 2*time.Second, waits[0])
+	assert.True(t, waits[1] >= retryBase && waits[1] <= 2*retryBase)
+	assert.Contains(t, debug.String(), "retry 1 of 3 in 2s")
+}
+
+func TestRetryLimit(t *testing.T) {
+	defer func(original func(context.Context, time.Duration) error) { sleep = original }(sleep)
+
+	sleep = func(context.Context, time.Duration) error { return nil }
+
+	requests := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v4/personal_access_tokens/self" {
+			w.Write([]byte(`{"scopes":["api"]}`))
+			return
+		}
+
+		requests++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	client := NewClient(&auth.Session{Token: "secret", Type: auth.PrivateToken, Host: server.URL})
+	client.Retries = 2
+
+	assert.True(t, errors.Is(client.Get("user", nil), ErrServer))
+	assert.Equal(t, 3, requests)
+
+	requests = 0
+	assert.True(t, errors.Is(client.Post("user", nil, nil), ErrServer))
+	assert.Equal(t, 1, requests)
+}
+
+func TestRetryAfter(t *testing.T) {
+	header := http.Header{}
+	assert.Equal(t, time.Duration(0), retryAfter(header))
+
+	header.Set("Retry-After", "30")
+	assert.Equal(t, 30*time.Second, retryAfter(header))
+
+	header = http.Header{}
+	header.Set("RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+	wait := retryAfter(header)
+	assert.True(t, wait > 58*time.Second && wait <= time.Minute)
+}
+
+func TestTimeout(t *testing.T) {
+	defer func(original func(context.Context, time.Duration) error) { sleep = original }(sleep)
+
+	sleep = func(context.Context, time.Duration) error { return nil }
+
+	release := make(chan struct{})
+	var requests int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := NewClient(&auth.Session{Token: "secret", Type: auth.PrivateToken, Host: server.URL})
+	client.Timeout = 10 * time.Millisecond
+	client.Retries = 1
+
+	err := client.Get("user", nil)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
+
+func TestCancel(t *testing.T) {
+	release := make(chan struct{})
+	var requests int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := NewClient(&auth.Session{Token: "secret", Type: auth.PrivateToken, Host: server.URL})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	var query struct {
+		CurrentUser struct {
+			Username string
+		}
+	}
+
+	err := client.QueryContext(ctx, &query, nil)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
+
+func TestGraphQLErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{
+			"data": {"project": {"name": "gitlabcli", "pipelines": null, "releases": null}},
+			"errors": [
+				{"message": "Access denied", "path": ["project", "pipelines"], "locations": [{"line": 1, "column": 30}], "extensions": {"code": "FORBIDDEN"}},
+				{"message": "Timeout", "path": ["project", "releases", "nodes", 0]}
+			]
+		}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(&auth.Session{Token: "secret", Type: auth.PrivateToken, Host: server.URL})
+
+	var query struct {
+		Project struct {
+			Name      string
+			Pipelines *struct{ Count int }
+			Releases  *struct{ Count int }
+		}
+	}
+
+	err := client.Query(&query, nil)
+
+	var graphqlErrors *GraphQLErrors
+	assert.True(t, errors.As(err, &graphqlErrors))
+	assert.True(t, graphqlErrors.Partial)
+	assert.Len(t, graphqlErrors.Errors, 2)
+	assert.Equal(t, "FORBIDDEN", graphqlErrors.Errors[0].Code())
+	assert.Equal(t, []Location{{Line: 1, Column: 30}}, graphqlErrors.Errors[0].Locations)
+	assert.Equal(t, "project.pipelines: Access denied\nproject.releases.nodes.0: Timeout", err.Error())
+	assert.Len(t, graphqlErrors.Under("project.releases"), 1)
+	assert.Len(t, graphqlErrors.Under("project.release"), 0)
+	assert.Equal(t, "gitlabcli", query.Project.Name)
+
+	failures, err := client.QueryPartial(context.Background(), &query, nil)
+	assert.NoError(t, err)
+	assert.Len(t, failures.Under("project"), 2)
+}
+
+func TestGraphQLErrorsWithoutData(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": {"project": null}, "errors": [{"message": "Field 'projec' doesn't exist"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(&auth.Session{Token: "secret", Type: auth.PrivateToken, Host: server.URL})
+
+	var query struct {
+		Project *struct{ Name string }
+	}
+
+	failures, err := client.QueryPartial(context.Background(), &query, nil)
+	assert.Nil(t, failures)
+	assert.EqualError(t, err, "Field 'projec' doesn't exist")
+}
+
+func TestMutationPayloadErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v4/personal_access_tokens/self" {
+			w.Write([]byte(`{"scopes":["api"]}`))
+			return
+		}
+
+		w.Write([]byte(`{"data":{"mergeRequestSetAssignees":{"mergeRequest":null,"errors":["User not found","Merge request is locked"]}}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(&auth.Session{Token: "secret", Type: auth.PrivateToken, Host: server.URL})
+
+	var mutation struct {
+		MergeRequestSetAssignees *struct {
+			MergeRequest *struct {
+				Iid string
+			}
+			Errors []string
+		}
+	}
+
+	err := client.Mutation(&mutation, nil)
+
+	var mutationErr *MutationError
+	assert.True(t, errors.As(err, &mutationErr))
+	assert.Equal(t, []string{"User not found", "Merge request is locked"}, mutationErr.Errors)
+	assert.EqualError(t, err, "mergeRequestSetAssignees failed: User not found, Merge request is locked")
+}
@@ -0,0 +1,322 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redacted are the headers with credentials, they are never logged
+var redacted = []string{"Authorization", "Private-Token", "Job-Token", "Cookie", "Set-Cookie"}
+
+// exchange is a request sent by the client and its response
+type exchange struct {
+	request     *http.Request
+	requestBody []byte
+	started     time.Time
+	elapsed     time.Duration
+	response    *http.Response
+	body        []byte
+	err         error
+}
+
+// tracing returns true if the requests are logged or traced
+func (c *Client) tracing() bool {
+	return c.Debug != nil || c.Trace != nil
+}
+
+// requestBody returns a copy of the body of the request
+func requestBody(req *http.Request) []byte {
+	if req.GetBody == nil {
+		return nil
+	}
+
+	body, err := req.GetBody()
+
+	if err != nil {
+		return nil
+	}
+
+	defer body.Close()
+	data, _ := ioutil.ReadAll(body)
+	return data
+}
+
+// trace write the exchange in the debug log and in the trace file
+func (c *Client) trace(ex *exchange) {
+	if c.Debug != nil {
+		fmt.Fprint(c.Debug, ex.summary())
+	}
+
+	if c.Trace != nil {
+		if err := c.Trace.add(ex); err != nil {
+			c.debugf("could not write the trace: %s", err.Error())
+		}
+	}
+}
+
+// summary returns the debug log lines of the exchange, the graphql
+// query and variables are pretty printed
+func (ex *exchange) summary() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "--> %s %s\n", ex.request.Method, ex.request.URL.String())
+
+	var graphqlBody struct {
+		Query     string
+		Variables json.RawMessage
+	}
+
+	if strings.HasSuffix(ex.request.URL.Path, graphql) && json.Unmarshal(ex.requestBody, &graphqlBody) == nil {
+		b.WriteString(prettyQuery(graphqlBody.Query))
+
+		if variables, err := json.MarshalIndent(graphqlBody.Variables, "", "  "); err == nil && len(graphqlBody.Variables) > 0 {
+			fmt.Fprintf(&b, "variables: %s\n", variables)
+		}
+	} else if len(ex.requestBody) > 0 {
+		fmt.Fprintf(&b, "%s\n", ex.requestBody)
+	}
+
+	elapsed := ex.elapsed.Round(time.Millisecond)
+
+	if ex.err != nil {
+		fmt.Fprintf(&b, "<-- %s (%s)\n", ex.err.Error(), elapsed)
+	} else {
+		fmt.Fprintf(&b, "<-- %s (%s, %d bytes)\n", ex.response.Status, elapsed, len(ex.body))
+	}
+
+	return b.String()
+}
+
+// prettyQuery returns the graphql query generated by the query builder
+// with a selection per line, the arguments are kept in the line of their field
+func prettyQuery(query string) string {
+	var b strings.Builder
+	var line strings.Builder
+
+	indent, parens := 0, 0
+	quoted, escaped := false, false
+
+	flush := func() {
+		if text := strings.TrimSpace(line.String()); text != "" {
+			fmt.Fprintf(&b, "%s%s\n", strings.Repeat("  ", indent), text)
+		}
+		line.Reset()
+	}
+
+	for _, char := range query {
+		switch {
+		case quoted:
+			line.WriteRune(char)
+			quoted = escaped || char != '"'
+			escaped = !escaped && char == '\\'
+			continue
+		case char == '"':
+			quoted = true
+		case char == '(':
+			parens++
+		case char == ')':
+			parens--
+
+			// the declarations of the variables end with a comma
+			if text := line.String(); strings.HasSuffix(text, ",") {
+				line.Reset()
+				line.WriteString(strings.TrimSuffix(text, ","))
+			}
+		}
+
+		if parens > 0 || quoted {
+			line.WriteRune(char)
+			continue
+		}
+
+		switch char {
+		case '{':
+			line.WriteString(" {")
+			flush()
+			indent++
+		case '}':
+			flush()
+			if indent > 0 {
+				indent--
+			}
+			line.WriteRune('}')
+			flush()
+		case ',':
+			flush()
+		default:
+			line.WriteRune(char)
+		}
+	}
+
+	flush()
+
+	return b.String()
+}
+
+// redactHeaders returns the headers in the har format without the credentials
+func redactHeaders(header http.Header) []harPair {
+	pairs := []harPair{}
+
+	for name, values := range header {
+		for _, value := range values {
+			for _, secret := range redacted {
+				if strings.EqualFold(name, secret) {
+					value = "[REDACTED]"
+				}
+			}
+
+			pairs = append(pairs, harPair{Name: name, Value: value})
+		}
+	}
+
+	return pairs
+}
+
+type harPair struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harRequest struct {
+	Method      string       `json:"method"`
+	URL         string       `json:"url"`
+	HTTPVersion string       `json:"httpVersion"`
+	Headers     []harPair    `json:"headers"`
+	QueryString []harPair    `json:"queryString"`
+	PostData    *harPostData `json:"postData,omitempty"`
+	HeadersSize int          `json:"headersSize"`
+	BodySize    int          `json:"bodySize"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harResponse struct {
+	Status      int        `json:"status"`
+	StatusText  string     `json:"statusText"`
+	HTTPVersion string     `json:"httpVersion"`
+	Headers     []harPair  `json:"headers"`
+	Content     harContent `json:"content"`
+	RedirectURL string     `json:"redirectURL"`
+	HeadersSize int        `json:"headersSize"`
+	BodySize    int        `json:"bodySize"`
+}
+
+type harTimings struct {
+	Send    int64 `json:"send"`
+	Wait    int64 `json:"wait"`
+	Receive int64 `json:"receive"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            int64       `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Timings         harTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+// Trace writes the requests of the client to a file in the HAR format,
+// to attach to bug reports. The credentials headers are redacted
+type Trace struct {
+	path    string
+	version string
+
+	mutex   sync.Mutex
+	entries []harEntry
+}
+
+// NewTrace returns a trace written to the given path,
+// version is the gitlabcli version recorded as creator
+func NewTrace(path string, version string) *Trace {
+	return &Trace{path: path, version: version}
+}
+
+// add append the exchange to the trace and write the file,
+// the file is complete after every request
+func (t *Trace) add(ex *exchange) error {
+	entry := harEntry{
+		StartedDateTime: ex.started.Format(time.RFC3339Nano),
+		Time:            ex.elapsed.Milliseconds(),
+		Request: harRequest{
+			Method:      ex.request.Method,
+			URL:         ex.request.URL.String(),
+			HTTPVersion: "HTTP/1.1",
+			Headers:     redactHeaders(ex.request.Header),
+			QueryString: []harPair{},
+			HeadersSize: -1,
+			BodySize:    len(ex.requestBody),
+		},
+		Response: harResponse{
+			HTTPVersion: "HTTP/1.1",
+			Headers:     []harPair{},
+			HeadersSize: -1,
+			BodySize:    len(ex.body),
+		},
+		Timings: harTimings{Send: 0, Wait: ex.elapsed.Milliseconds(), Receive: 0},
+	}
+
+	for name, values := range ex.request.URL.Query() {
+		for _, value := range values {
+			entry.Request.QueryString = append(entry.Request.QueryString, harPair{Name: name, Value: value})
+		}
+	}
+
+	if len(ex.requestBody) > 0 {
+		entry.Request.PostData = &harPostData{
+			MimeType: ex.request.Header.Get("Content-Type"),
+			Text:     string(ex.requestBody),
+		}
+	}
+
+	if ex.err != nil {
+		entry.Comment = ex.err.Error()
+	}
+
+	if ex.response != nil {
+		entry.Response.Status = ex.response.StatusCode
+		entry.Response.StatusText = http.StatusText(ex.response.StatusCode)
+		entry.Response.HTTPVersion = ex.response.Proto
+		entry.Response.Headers = redactHeaders(ex.response.Header)
+		entry.Response.Content = harContent{
+			Size:     len(ex.body),
+			MimeType: ex.response.Header.Get("Content-Type"),
+			Text:     string(ex.body),
+		}
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.entries = append(t.entries, entry)
+
+	har := map[string]interface{}{
+		"log": map[string]interface{}{
+			"version": "1.2",
+			"creator": map[string]string{"name": "gitlabcli", "version": t.version},
+			"entries": t.entries,
+		},
+	}
+
+	data, err := json.MarshalIndent(har, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(t.path, data, 0600)
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/angel-afonso/gitlabcli/auth"
+)
+
+func TestPrettyQuery(t *testing.T) {
+	assert.Equal(t,
+		"query($path:ID!) {\n  project(fullPath:$path) {\n    name\n    issues(labelName: [\"a,b\", \"{c}\"]) {\n      count\n    }\n  }\n}\n",
+		prettyQuery(`query($path:ID!,){project(fullPath:$path){name,issues(labelName: ["a,b", "{c}"]){count,}}}`))
+}
+
+func TestDebugLog(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":{"project":{"name":"gitlabcli"}}}`))
+	}))
+	defer server.Close()
+
+	var log bytes.Buffer
+
+	client := NewClient(&auth.Session{Token: "secret", Type: auth.PrivateToken, Host: server.URL})
+	client.Debug = &log
+
+	var query struct {
+		Project struct {
+			Name string
+		} `graphql:"(fullPath:$path)"`
+	}
+
+	assert.NoError(t, client.Query(&query, struct{ path string }{"a/b"}))
+
+	output := log.String()
+	assert.Contains(t, output, "--> POST "+server.URL+"/api/graphql\n")
+	assert.Contains(t, output, "query($path:String) {\n  project(fullPath:$path) {\n    name\n  }\n}\n")
+	assert.Contains(t, output, "variables: {\n  \"path\": \"a/b\"\n}\n")
+	assert.Contains(t, output, "<-- 200 OK (")
+	assert.Contains(t, output, "41 bytes)")
+	assert.NotContains(t, output, "secret")
+}
+
+func TestTrace(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "_gitlab_session=secret")
+		w.Write([]byte(`{"username":"root"}`))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "trace")
+	assert.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "trace.har")
+
+	for _, session := range []*auth.Session{
+		{Token: "secret", Type: auth.PrivateToken, Host: server.URL},
+		{Token: "secret", Type: auth.JobToken, Host: server.URL},
+		{Token: "secret", Type: "Bearer", Host: server.URL},
+	} {
+		client := NewClient(session)
+		client.Trace = NewTrace(path, "1.0.0")
+
+		var user struct{ Username string }
+		assert.NoError(t, client.Get("job", &user))
+
+		data, err := ioutil.ReadFile(path)
+		assert.NoError(t, err)
+		assert.NotContains(t, string(data), "secret")
+
+		var har struct {
+			Log struct {
+				Creator struct{ Version string }
+				Entries []harEntry
+			}
+		}
+
+		assert.NoError(t, json.Unmarshal(data, &har))
+		assert.Equal(t, "1.0.0", har.Log.Creator.Version)
+		assert.Len(t, har.Log.Entries, 1)
+
+		entry := har.Log.Entries[0]
+		assert.Equal(t, "GET", entry.Request.Method)
+		assert.Equal(t, server.URL+"/api/v4/job", entry.Request.URL)
+		assert.Contains(t, entry.Request.Headers, harPair{Name: http.CanonicalHeaderKey(map[string]string{
+			auth.PrivateToken: "PRIVATE-TOKEN",
+			auth.JobToken:     "JOB-TOKEN",
+			"Bearer":          "Authorization",
+		}[session.Type]), Value: "[REDACTED]"})
+		assert.Equal(t, 200, entry.Response.Status)
+		assert.Equal(t, `{"username":"root"}`, entry.Response.Content.Text)
+	}
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+)
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// isNull returns true for the json null value
+func isNull(data json.RawMessage) bool {
+	return len(data) == 0 || bytes.Equal(bytes.TrimSpace(data), []byte("null"))
+}
+
+// decode bind the json of a selection generated by the query builder in the value,
+// the object keys are the response names of the fields and the fragments
+// are bound from the object of their parent
+func decode(data json.RawMessage, value reflect.Value) error {
+	if value.CanAddr() && value.Addr().Type().Implements(unmarshalerType) {
+		return json.Unmarshal(data, value.Addr().Interface())
+	}
+
+	switch value.Kind() {
+	case reflect.Ptr:
+		if isNull(data) {
+			value.Set(reflect.Zero(value.Type()))
+			return nil
+		}
+
+		if value.IsNil() {
+			value.Set(reflect.New(value.Type().Elem()))
+		}
+
+		return decode(data, value.Elem())
+	case reflect.Struct:
+		if isNull(data) {
+			value.Set(reflect.Zero(value.Type()))
+			return nil
+		}
+
+		var object map[string]json.RawMessage
+
+		if err := json.Unmarshal(data, &object); err != nil {
+			return err
+		}
+
+		return decodeObject(object, value)
+	case reflect.Slice:
+		if isNull(data) {
+			value.Set(reflect.Zero(value.Type()))
+			return nil
+		}
+
+		var items []json.RawMessage
+
+		if err := json.Unmarshal(data, &items); err != nil {
+			return err
+		}
+
+		slice := reflect.MakeSlice(value.Type(), len(items), len(items))
+
+		for i, item := range items {
+			if err := decode(item, slice.Index(i)); err != nil {
+				return err
+			}
+		}
+
+		value.Set(slice)
+		return nil
+	}
+
+	return json.Unmarshal(data, value.Addr().Interface())
+}
+
+// decodeObject bind the fields of the response object in the struct value,
+// the fragments are bound if the object has their marker
+func decodeObject(object map[string]json.RawMessage, value reflect.Value) error {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		fieldValue := value.Field(i)
+
+		if onType, _, ok := fragmentCondition(field); ok {
+			if _, matched := object[fragmentMarker(onType)]; !matched {
+				continue
+			}
+
+			if err := decodeFragment(object, fieldValue); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		switch field.Tag.Get("graphql") {
+		case "-":
+			continue
+		case "inner":
+			if err := decodeFragment(object, fieldValue); err != nil {
+				return err
+			}
+			continue
+		}
+
+		data, ok := object[responseName(field)]
+
+		if !ok || !fieldValue.CanSet() {
+			continue
+		}
+
+		if err := decode(data, fieldValue); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decodeFragment bind the object of the parent in the fragment struct,
+// pointers are set for the fragments whose type condition matched
+func decodeFragment(object map[string]json.RawMessage, value reflect.Value) error {
+	if value.Kind() == reflect.Ptr {
+		if !value.CanSet() {
+			return nil
+		}
+
+		if value.IsNil() {
+			value.Set(reflect.New(value.Type().Elem()))
+		}
+
+		value = value.Elem()
+	}
+
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	return decodeObject(object, value)
+}
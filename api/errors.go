@@ -0,0 +1,194 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors matched by the response errors of each status class,
+// check them with errors.Is
+var (
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrServer       = errors.New("server error")
+)
+
+// ResponseError is returned for responses with an error status
+type ResponseError struct {
+	StatusCode int
+	Method     string
+	Path       string
+	// Message is the error message returned by gitlab, if any
+	Message string
+	// RetryAfter is the wait requested by the rate limit headers, zero if none
+	RetryAfter time.Duration
+}
+
+func (e *ResponseError) Error() string {
+	status := fmt.Sprintf("%s %s: %d %s", e.Method, e.Path, e.StatusCode, http.StatusText(e.StatusCode))
+
+	if e.Message == "" {
+		return status
+	}
+
+	return fmt.Sprintf("%s: %s", status, e.Message)
+}
+
+// Unwrap returns the error of the status class
+func (e *ResponseError) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusUnauthorized:
+		return ErrUnauthorized
+	case e.StatusCode == http.StatusForbidden:
+		return ErrForbidden
+	case e.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case e.StatusCode >= http.StatusInternalServerError:
+		return ErrServer
+	}
+	return nil
+}
+
+// newResponseError build the error of a response, the message is taken
+// from the json body. Html error pages are ignored
+func newResponseError(req *http.Request, status int, body []byte) *ResponseError {
+	return &ResponseError{
+		StatusCode: status,
+		Method:     req.Method,
+		Path:       req.URL.Path,
+		Message:    errorMessage(body),
+	}
+}
+
+// errorMessage extract the message of gitlab error responses, like
+// {"message": "404 Project Not Found"}, {"message": {"title": ["is too long"]}}
+// or {"error": "invalid_token", "error_description": "Token is expired"}
+func errorMessage(body []byte) string {
+	var response struct {
+		Message          interface{}
+		Error            string
+		ErrorDescription string `json:"error_description"`
+	}
+
+	if err := json.Unmarshal(body, &response); err != nil {
+		return ""
+	}
+
+	switch message := response.Message.(type) {
+	case string:
+		return message
+	case map[string]interface{}:
+		var messages []string
+
+		for field, value := range message {
+			messages = append(messages, fmt.Sprintf("%s %v", field, value))
+		}
+
+		sort.Strings(messages)
+		return strings.Join(messages, ", ")
+	}
+
+	if response.ErrorDescription != "" {
+		return response.ErrorDescription
+	}
+
+	return response.Error
+}
+
+// Location is the position in the query of a graphql error
+type Location struct {
+	Line   int
+	Column int
+}
+
+// GraphQLError is an error of a graphql response
+type GraphQLError struct {
+	Message string
+	// Path of the field that failed, with the names of the fields and the indexes of the lists
+	Path       []interface{}
+	Locations  []Location
+	Extensions map[string]interface{}
+}
+
+// PathString returns the path of the field that failed separated by dots
+func (e *GraphQLError) PathString() string {
+	parts := make([]string, len(e.Path))
+
+	for i, part := range e.Path {
+		switch value := part.(type) {
+		case float64:
+			parts[i] = strconv.Itoa(int(value))
+		default:
+			parts[i] = fmt.Sprint(value)
+		}
+	}
+
+	return strings.Join(parts, ".")
+}
+
+// Code returns the error code of the extensions, empty if it has none
+func (e *GraphQLError) Code() string {
+	code, _ := e.Extensions["code"].(string)
+	return code
+}
+
+func (e *GraphQLError) Error() string {
+	if len(e.Path) == 0 {
+		return e.Message
+	}
+
+	return fmt.Sprintf("%s: %s", e.PathString(), e.Message)
+}
+
+// GraphQLErrors is returned with every error of a graphql response,
+// the data of the fields without errors is bound anyway
+type GraphQLErrors struct {
+	Errors []GraphQLError
+	// Partial is true if the response has data besides the failed fields
+	Partial bool
+}
+
+func (e *GraphQLErrors) Error() string {
+	messages := make([]string, len(e.Errors))
+
+	for i := range e.Errors {
+		messages[i] = e.Errors[i].Error()
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// Under returns the errors of the field in the given dot separated path,
+// or of the fields inside it
+func (e *GraphQLErrors) Under(path string) []GraphQLError {
+	var errors []GraphQLError
+
+	for _, err := range e.Errors {
+		if failed := err.PathString(); failed == path || strings.HasPrefix(failed, path+".") {
+			errors = append(errors, err)
+		}
+	}
+
+	return errors
+}
+
+// MutationError is returned when the payload of a mutation has errors
+type MutationError struct {
+	// Mutation is the name of the mutation field
+	Mutation string
+	Errors   []string
+}
+
+func (e *MutationError) Error() string {
+	return fmt.Sprintf("%s failed: %s", e.Mutation, strings.Join(e.Errors, ", "))
+}
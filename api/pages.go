@@ -0,0 +1,165 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Pages request the pages of a rest list endpoint, following
+// the Link and X-Next-Page headers of the responses
+//
+//	pages := client.GetPages(ctx, "projects/1/users")
+//	pages.PerPage = 100
+//
+//	var users []User
+//
+//	for pages.Next(&users) {
+//		// users has the items of the page
+//	}
+//
+//	if err := pages.Err(); err != nil {
+//		...
+//	}
+type Pages struct {
+	// PerPage is the number of items per page, gitlab returns 20
+	// if it is not set and allows up to 100
+	PerPage int
+
+	client *Client
+	ctx    context.Context
+	next   string
+	total  int
+	err    error
+}
+
+// GetPages returns the pages of the rest list endpoint in the given path
+func (c *Client) GetPages(ctx context.Context, path string) *Pages {
+	return &Pages{client: c, ctx: ctx, next: path, total: -1}
+}
+
+// GetAll request every page of the rest list endpoint in the given path
+// and append the items to the slice pointed by bind
+func (c *Client) GetAll(ctx context.Context, path string, bind interface{}) error {
+	slice := reflect.ValueOf(bind)
+
+	if slice.Kind() != reflect.Ptr || slice.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("GetAll needs a pointer to slice, got %T", bind)
+	}
+
+	pages := c.GetPages(ctx, path)
+	pages.PerPage = 100
+
+	page := reflect.New(slice.Elem().Type())
+
+	for pages.Next(page.Interface()) {
+		slice.Elem().Set(reflect.AppendSlice(slice.Elem(), page.Elem()))
+	}
+
+	return pages.Err()
+}
+
+// Next request the next page and bind its items in the given slice pointer,
+// returns false when there are no more pages or the request failed
+func (p *Pages) Next(bind interface{}) bool {
+	if p.err != nil || p.next == "" {
+		return false
+	}
+
+	path := p.next
+
+	if p.PerPage > 0 && !strings.Contains(path, "per_page=") {
+		path = withParam(path, "per_page", strconv.Itoa(p.PerPage))
+	}
+
+	req, err := p.client.restReq(p.ctx, get, path, nil)
+
+	if err != nil {
+		p.err = err
+		return false
+	}
+
+	body, header, err := p.client.send(req, true)
+
+	if err != nil {
+		p.err = err
+		return false
+	}
+
+	if total, err := strconv.Atoi(header.Get("X-Total")); err == nil {
+		p.total = total
+	}
+
+	p.next = p.client.nextPage(path, header)
+
+	reflect.ValueOf(bind).Elem().Set(reflect.Zero(reflect.TypeOf(bind).Elem()))
+
+	if p.err = bindRestResponse(body, bind); p.err != nil {
+		return false
+	}
+
+	return true
+}
+
+// Total returns the number of items of the list given by the X-Total header,
+// -1 if it is unknown. Gitlab omits it for lists of more than 10000 items
+func (p *Pages) Total() int {
+	return p.total
+}
+
+// Err returns the error of the last request
+func (p *Pages) Err() error {
+	return p.err
+}
+
+// nextPage returns the api path of the page after the given one, from the
+// Link header or the X-Next-Page header. Empty when it is the last page
+func (c *Client) nextPage(path string, header http.Header) string {
+	base := c.endpoint(rest) + "/"
+
+	for _, link := range strings.Split(header.Get("Link"), ",") {
+		parts := strings.Split(link, ";")
+
+		if len(parts) < 2 {
+			continue
+		}
+
+		target := strings.Trim(strings.TrimSpace(parts[0]), "<>")
+
+		for _, param := range parts[1:] {
+			// links to other hosts are ignored, they would receive the token
+			if strings.TrimSpace(param) == `rel="next"` && strings.HasPrefix(target, base) {
+				return strings.TrimPrefix(target, base)
+			}
+		}
+	}
+
+	if page := header.Get("X-Next-Page"); page != "" {
+		return withParam(path, "page", page)
+	}
+
+	return ""
+}
+
+// withParam returns the path with the query parameter set to the given value
+func withParam(path string, name string, value string) string {
+	query := ""
+
+	if index := strings.Index(path, "?"); index >= 0 {
+		path, query = path[:index], path[index+1:]
+	}
+
+	params, err := url.ParseQuery(query)
+
+	if err != nil {
+		params = url.Values{}
+	}
+
+	params.Set(name, value)
+
+	return fmt.Sprintf("%s?%s", path, params.Encode())
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/angel-afonso/gitlabcli/auth"
+)
+
+// usersServer serves 5 users in pages, with Link headers or with X-Next-Page if link is false
+func usersServer(t *testing.T, link bool) *httptest.Server {
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v4/projects/group%2Fproject/users", r.URL.RawPath)
+
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+
+		if page == 0 {
+			page = 1
+		}
+
+		start, end := (page-1)*perPage, page*perPage
+
+		if end > 5 {
+			end = 5
+		}
+
+		if end < 5 {
+			if link {
+				w.Header().Set("Link", fmt.Sprintf(`<%s/api/v4/projects/group%%2Fproject/users?page=%d&per_page=%d>; rel="next", <https://other.example.com/>; rel="first"`, server.URL, page+1, perPage))
+			} else {
+				w.Header().Set("X-Next-Page", strconv.Itoa(page+1))
+			}
+		}
+
+		w.Header().Set("X-Total", "5")
+
+		users := "["
+
+		for i := start; i < end; i++ {
+			if i > start {
+				users += ","
+			}
+			users += fmt.Sprintf(`{"username":"user%d"}`, i+1)
+		}
+
+		w.Write([]byte(users + "]"))
+	}))
+
+	return server
+}
+
+func TestGetPages(t *testing.T) {
+	for _, link := range []bool{true, false} {
+		server := usersServer(t, link)
+
+		client := NewClient(&auth.Session{Token: "secret", Type: auth.PrivateToken, Host: server.URL})
+
+		pages := client.GetPages(context.Background(), "projects/group%2Fproject/users")
+		pages.PerPage = 2
+
+		var sizes []int
+		var users []struct{ Username string }
+
+		assert.Equal(t, -1, pages.Total())
+
+		for pages.Next(&users) {
+			sizes = append(sizes, len(users))
+		}
+
+		assert.NoError(t, pages.Err())
+		assert.Equal(t, []int{2, 2, 1}, sizes)
+		assert.Equal(t, "user5", users[0].Username)
+		assert.Equal(t, 5, pages.Total())
+
+		server.Close()
+	}
+}
+
+func TestGetAll(t *testing.T) {
+	server := usersServer(t, true)
+	defer server.Close()
+
+	client := NewClient(&auth.Session{Token: "secret", Type: auth.PrivateToken, Host: server.URL})
+
+	var users []struct{ Username string }
+
+	assert.NoError(t, client.GetAll(context.Background(), "projects/group%2Fproject/users?per_page=2", &users))
+	assert.Len(t, users, 5)
+	assert.Equal(t, "user1", users[0].Username)
+	assert.Equal(t, "user5", users[4].Username)
+
+	assert.Error(t, client.GetAll(context.Background(), "projects/group%2Fproject/users", users))
+}
+
+func TestNextPage(t *testing.T) {
+	client := NewClient(&auth.Session{Host: "https://gitlab.example.com"})
+
+	header := http.Header{}
+	header.Set("Link", `<https://evil.example.com/api/v4/users?page=2>; rel="next"`)
+	header.Set("X-Next-Page", "2")
+
+	assert.Equal(t, "users?page=2&per_page=10", client.nextPage("users?per_page=10", header))
+
+	header.Set("Link", `<https://gitlab.example.com/api/v4/users?id_after=42&per_page=10>; rel="next"`)
+	assert.Equal(t, "users?id_after=42&per_page=10", client.nextPage("users?per_page=10", header))
+
+	assert.Equal(t, "", client.nextPage("users", http.Header{}))
+}
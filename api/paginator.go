@@ -0,0 +1,242 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// DefaultPageSize is the number of nodes requested per page
+const DefaultPageSize = 20
+
+// PageInfo is the pagination state of a graphql connection
+type PageInfo struct {
+	EndCursor   string
+	HasNextPage bool
+}
+
+// Paginator request the pages of a graphql connection in the query struct.
+// The connection must have the PageInfo and Nodes fields, and use the
+// $first and $after variables that the paginator adds to the query:
+//
+//	var query struct {
+//		Project *struct {
+//			Issues struct {
+//				PageInfo api.PageInfo
+//				Nodes    []Issue
+//			} `graphql:"(first: $first, after: $after)"`
+//		} `graphql:"(fullPath: $path)"`
+//	}
+//
+//	pages := client.Paginate(ctx, &query, variables, "Project.Issues")
+//
+//	for pages.Next() {
+//		// query.Project.Issues.Nodes has the nodes of the page
+//	}
+//
+//	if err := pages.Err(); err != nil {
+//		...
+//	}
+//
+// Or node by node with NextNode, the pages are requested as needed:
+//
+//	var issue Issue
+//
+//	for pages.NextNode(&issue) {
+//		// issue is the next node of the connection
+//	}
+type Paginator struct {
+	// Limit is the maximum number of nodes requested, zero requests every page
+	Limit int
+	// PageSize is the number of nodes requested per page
+	PageSize int
+
+	client    *Client
+	ctx       context.Context
+	query     interface{}
+	variables interface{}
+	path      []string
+
+	after string
+	count int
+	done  bool
+	err   error
+
+	// loaded is true after the first page of NextNode, index is its next node
+	loaded bool
+	index  int
+}
+
+// Paginate returns a paginator of the connection in the given path of the query,
+// the path is the dot separated names of the struct fields
+func (c *Client) Paginate(ctx context.Context, query interface{}, variables interface{}, path string) *Paginator {
+	p := &Paginator{
+		PageSize:  DefaultPageSize,
+		client:    c,
+		ctx:       ctx,
+		query:     query,
+		variables: variables,
+		path:      strings.Split(path, "."),
+	}
+
+	p.err = p.validate()
+
+	return p
+}
+
+// validate check that the query struct has a connection in the path
+func (p *Paginator) validate() error {
+	queryType := reflect.TypeOf(p.query)
+
+	if queryType == nil || queryType.Kind() != reflect.Ptr {
+		return fmt.Errorf("the paginated query must be a pointer to struct")
+	}
+
+	queryType = queryType.Elem()
+
+	for _, name := range p.path {
+		for queryType.Kind() == reflect.Ptr {
+			queryType = queryType.Elem()
+		}
+
+		field, ok := queryType.FieldByName(name)
+
+		if queryType.Kind() != reflect.Struct || !ok {
+			return fmt.Errorf("field %s of the connection path %s not found in the query", name, strings.Join(p.path, "."))
+		}
+
+		queryType = field.Type
+	}
+
+	if info, ok := queryType.FieldByName("PageInfo"); !ok || info.Type != reflect.TypeOf(PageInfo{}) {
+		return fmt.Errorf("the connection %s needs a PageInfo field of type api.PageInfo", strings.Join(p.path, "."))
+	}
+
+	if nodes, ok := queryType.FieldByName("Nodes"); !ok || nodes.Type.Kind() != reflect.Slice {
+		return fmt.Errorf("the connection %s needs a Nodes slice", strings.Join(p.path, "."))
+	}
+
+	return nil
+}
+
+// connection returns the connection value of the query,
+// invalid if a struct in the path is nil
+func (p *Paginator) connection() reflect.Value {
+	value := reflect.ValueOf(p.query)
+
+	for _, name := range p.path {
+		for value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				return reflect.Value{}
+			}
+			value = value.Elem()
+		}
+
+		value = value.FieldByName(name)
+	}
+
+	return value
+}
+
+// Next request the next page into the query struct,
+// returns false when there are no more pages or the request failed
+func (p *Paginator) Next() bool {
+	if p.err != nil || p.done {
+		return false
+	}
+
+	first := p.PageSize
+
+	if p.Limit > 0 && p.Limit-p.count < first {
+		first = p.Limit - p.count
+	}
+
+	var after interface{}
+
+	if p.after != "" {
+		after = p.after
+	}
+
+	p.err = p.client.query(p.ctx, p.query, p.variables, pageVariables(first, after)...)
+
+	if p.err != nil {
+		return false
+	}
+
+	connection := p.connection()
+
+	if !connection.IsValid() {
+		p.done = true
+		return true
+	}
+
+	info := connection.FieldByName("PageInfo")
+
+	p.count += connection.FieldByName("Nodes").Len()
+	p.after = info.FieldByName("EndCursor").String()
+	p.done = !info.FieldByName("HasNextPage").Bool() || (p.Limit > 0 && p.count >= p.Limit)
+
+	return true
+}
+
+// NextNode set the next node of the connection in the given pointer, requesting
+// the next page when the nodes of the current one are done. Returns false when
+// there are no more nodes or the request failed. Do not mix it with Next
+func (p *Paginator) NextNode(node interface{}) bool {
+	target := reflect.ValueOf(node)
+
+	if target.Kind() != reflect.Ptr || target.IsNil() {
+		p.err = fmt.Errorf("NextNode needs a pointer to the node type")
+		return false
+	}
+
+	for {
+		if connection := p.connection(); p.loaded && connection.IsValid() {
+			nodes := connection.FieldByName("Nodes")
+
+			if p.index < nodes.Len() {
+				value := nodes.Index(p.index)
+
+				if !value.Type().AssignableTo(target.Elem().Type()) {
+					p.err = fmt.Errorf("the nodes of %s are %s, not %s", strings.Join(p.path, "."), value.Type(), target.Elem().Type())
+					return false
+				}
+
+				p.index++
+				target.Elem().Set(value)
+				return true
+			}
+		}
+
+		if !p.Next() {
+			return false
+		}
+
+		p.loaded = true
+		p.index = 0
+	}
+}
+
+// pageVariables returns the $first and $after variables added to the paginated queries
+func pageVariables(first int, after interface{}) []variable {
+	return []variable{
+		{name: "first", varType: "Int", value: first},
+		{name: "after", varType: "String", value: after},
+	}
+}
+
+// HasMore returns true if there are pages after the current one
+func (p *Paginator) HasMore() bool {
+	return p.err == nil && !p.done
+}
+
+// Count returns the number of nodes received
+func (p *Paginator) Count() int {
+	return p.count
+}
+
+// Err returns the error of the last request, or of the query struct
+func (p *Paginator) Err() error {
+	return p.err
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/angel-afonso/gitlabcli/auth"
+)
+
+type paginatedQuery struct {
+	Project *struct {
+		Issues struct {
+			PageInfo PageInfo
+			Nodes    []struct {
+				Iid string
+			}
+		} `graphql:"(first: $first, after: $after)"`
+	} `graphql:"(fullPath: $path)"`
+}
+
+// issuesServer serves the given number of issues in pages of the requested size
+func issuesServer(t *testing.T, total int, requests *[]map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Variables map[string]interface{}
+		}
+
+		json.NewDecoder(r.Body).Decode(&body)
+		*requests = append(*requests, body.Variables)
+
+		start := 0
+
+		if after, ok := body.Variables["after"].(string); ok {
+			fmt.Sscan(after, &start)
+		}
+
+		end := start + int(body.Variables["first"].(float64))
+
+		if end > total {
+			end = total
+		}
+
+		var nodes []string
+
+		for i := start; i < end; i++ {
+			nodes = append(nodes, fmt.Sprintf(`{"iid":"%d"}`, i+1))
+		}
+
+		fmt.Fprintf(w, `{"data":{"project":{"issues":{"pageInfo":{"endCursor":"%d","hasNextPage":%t},"nodes":[%s]}}}}`,
+			end, end < total, strings.Join(nodes, ","))
+	}))
+}
+
+func TestPaginator(t *testing.T) {
+	var requests []map[string]interface{}
+
+	server := issuesServer(t, 5, &requests)
+	defer server.Close()
+
+	client := NewClient(&auth.Session{Token: "secret", Type: auth.PrivateToken, Host: server.URL})
+
+	var query paginatedQuery
+	var iids []string
+
+	pages := client.Paginate(context.Background(), &query, struct{ path string }{"group/project"}, "Project.Issues")
+	pages.PageSize = 2
+
+	for pages.Next() {
+		for _, issue := range query.Project.Issues.Nodes {
+			iids = append(iids, issue.Iid)
+		}
+	}
+
+	assert.NoError(t, pages.Err())
+	assert.Equal(t, []string{"1", "2", "3", "4", "5"}, iids)
+	assert.Len(t, requests, 3)
+	assert.Nil(t, requests[0]["after"])
+	assert.Equal(t, "group/project", requests[0]["path"])
+	assert.Equal(t, "2", requests[1]["after"])
+}
+
+func TestPaginatorNodes(t *testing.T) {
+	var requests []map[string]interface{}
+
+	server := issuesServer(t, 5, &requests)
+	defer server.Close()
+
+	client := NewClient(&auth.Session{Token: "secret", Type: auth.PrivateToken, Host: server.URL})
+
+	var query paginatedQuery
+	var issue struct {
+		Iid string
+	}
+	var iids []string
+
+	pages := client.Paginate(context.Background(), &query, struct{ path string }{"group/project"}, "Project.Issues")
+	pages.PageSize = 2
+
+	for pages.NextNode(&issue) {
+		iids = append(iids, issue.Iid)
+	}
+
+	assert.NoError(t, pages.Err())
+	assert.Equal(t, []string{"1", "2", "3", "4", "5"}, iids)
+	assert.Len(t, requests, 3)
+
+	var wrong string
+
+	pages = client.Paginate(context.Background(), &query, struct{ path string }{"group/project"}, "Project.Issues")
+	assert.False(t, pages.NextNode(&wrong))
+	assert.EqualError(t, pages.Err(), "the nodes of Project.Issues are struct { Iid string }, not string")
+}
+
+func TestPaginatorLimit(t *testing.T) {
+	var requests []map[string]interface{}
+
+	server := issuesServer(t, 50, &requests)
+	defer server.Close()
+
+	client := NewClient(&auth.Session{Token: "secret", Type: auth.PrivateToken, Host: server.URL})
+
+	var query paginatedQuery
+
+	pages := client.Paginate(context.Background(), &query, struct{ path string }{"group/project"}, "Project.Issues")
+	pages.PageSize = 4
+	pages.Limit = 6
+
+	for pages.Next() {
+	}
+
+	assert.NoError(t, pages.Err())
+	assert.Equal(t, 6, pages.Count())
+	assert.Len(t, requests, 2)
+	assert.Equal(t, float64(2), requests[1]["first"])
+	assert.False(t, pages.HasMore())
+}
+
+func TestPaginatorErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := NewClient(&auth.Session{Token: "secret", Type: auth.PrivateToken, Host: server.URL})
+
+	var query paginatedQuery
+
+	pages := client.Paginate(context.Background(), &query, nil, "Project.Issues")
+	assert.False(t, pages.Next())
+	assert.True(t, errors.Is(pages.Err(), ErrNotFound))
+
+	pages = client.Paginate(context.Background(), &query, nil, "Project.MergeRequests")
+	assert.False(t, pages.Next())
+	assert.EqualError(t, pages.Err(), "field MergeRequests of the connection path Project.MergeRequests not found in the query")
+}
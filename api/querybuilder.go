@@ -2,6 +2,9 @@ package api
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"reflect"
@@ -11,8 +14,12 @@ import (
 )
 
 // graphqlReq generate request pointer
-func graphqlReq(data *strings.Reader) (*http.Request, error) {
-	req, err := http.NewRequest(post, graphql, data)
+func (c *Client) graphqlReq(ctx context.Context, data *strings.Reader) (*http.Request, error) {
+	if err := c.allowed(""); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, post, c.endpoint(graphql), data)
 
 	if err != nil {
 		return nil, err
@@ -23,13 +30,38 @@ func graphqlReq(data *strings.Reader) (*http.Request, error) {
 
 // Query Send a query graphql request
 func (c *Client) Query(query interface{}, variables interface{}) error {
-	req, err := graphqlReq(strings.NewReader(formatQuery(query, variables)))
+	return c.QueryContext(context.Background(), query, variables)
+}
+
+// QueryContext send a query graphql request, canceled with the given context
+func (c *Client) QueryContext(ctx context.Context, query interface{}, variables interface{}) error {
+	return c.query(ctx, query, variables)
+}
+
+// QueryPartial send a query graphql request accepting partial results, the data of the
+// fields that did not fail is bound and their errors are returned apart.
+// The error is returned if the request failed or the response has no data
+func (c *Client) QueryPartial(ctx context.Context, query interface{}, variables interface{}) (*GraphQLErrors, error) {
+	err := c.QueryContext(ctx, query, variables)
+
+	var graphqlErrors *GraphQLErrors
+
+	if errors.As(err, &graphqlErrors) && graphqlErrors.Partial {
+		return graphqlErrors, nil
+	}
+
+	return nil, err
+}
+
+// query send a query with the variables of the struct and the extra ones
+func (c *Client) query(ctx context.Context, query interface{}, variables interface{}, extra ...variable) error {
+	req, err := c.graphqlReq(ctx, strings.NewReader(formatQuery(query, variables, extra...)))
 
 	if err != nil {
 		return err
 	}
 
-	bytes, err := c.send(req)
+	bytes, _, err := c.send(req, true)
 
 	if err != nil {
 		return err
@@ -40,174 +72,423 @@ func (c *Client) Query(query interface{}, variables interface{}) error {
 
 // Mutation Send a mutation graphql request
 func (c *Client) Mutation(mutation interface{}, vars interface{}) error {
-	req, err := graphqlReq(strings.NewReader(formatMutation(mutation, vars)))
+	return c.MutationContext(context.Background(), mutation, vars)
+}
+
+// MutationContext send a mutation graphql request, canceled with the given context
+func (c *Client) MutationContext(ctx context.Context, mutation interface{}, vars interface{}) error {
+	if err := c.RequireScope(WriteScope); err != nil {
+		return err
+	}
+
+	req, err := c.graphqlReq(ctx, strings.NewReader(formatMutation(mutation, vars)))
 
 	if err != nil {
 		return err
 	}
 
-	bytes, err := c.send(req)
+	bytes, _, err := c.send(req, false)
 
 	if err != nil {
 		return err
 	}
 
-	return bindGraphqlResponse(bytes, mutation)
+	if err := bindGraphqlResponse(bytes, mutation); err != nil {
+		return err
+	}
+
+	return payloadErrors(mutation)
+}
+
+// payloadErrors returns a MutationError for the first mutation payload
+// with an Errors []string field that is not empty
+func payloadErrors(mutation interface{}) error {
+	value := reflect.Indirect(reflect.ValueOf(mutation))
+
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	for i := 0; i < value.NumField(); i++ {
+		payload := reflect.Indirect(value.Field(i))
+
+		if payload.Kind() != reflect.Struct {
+			continue
+		}
+
+		errorsField := payload.FieldByName("Errors")
+
+		if !errorsField.IsValid() || errorsField.Type() != reflect.TypeOf([]string{}) || errorsField.Len() == 0 {
+			continue
+		}
+
+		messages := make([]string, errorsField.Len())
+
+		for j := range messages {
+			messages[j] = errorsField.Index(j).String()
+		}
+
+		return &MutationError{Mutation: responseName(value.Type().Field(i)), Errors: messages}
+	}
+
+	return nil
 }
 
 // generateQueryBody format a string as a graphql query body
 func generateQueryBody(query interface{}) string {
-	var structType reflect.Type
+	return newQueryBuilder().body(query)
+}
 
-	if refl := reflect.TypeOf(query); refl.Kind() == reflect.Ptr {
-		structType = refl.Elem()
-	} else {
-		structType = refl
-	}
+// generateDocument returns the graphql document of the operation, with the
+// selection of the query struct and the definitions of its named fragments
+func generateDocument(operation string, query interface{}) string {
+	builder := newQueryBuilder()
+	body := builder.body(query)
 
-	return parseInnerFields(structType)
+	return fmt.Sprintf("%s{%s}%s", operation, body, strings.Join(builder.fragments, ""))
 }
 
 // formatMutation format a string as a graphql mutation
 func formatMutation(mutation interface{}, vars interface{}) string {
 	queryVars, variables := formatVariables(vars)
 
-	return fmt.Sprintf(`{"query":"%s{%s}",%s}`,
-		fmt.Sprintf("mutation(%s)", queryVars),
-		generateQueryBody(mutation), variables)
+	return fmt.Sprintf(`{"query":%s,%s}`,
+		encodeJSON(generateDocument(fmt.Sprintf("mutation(%s)", queryVars), mutation)),
+		variables)
 }
 
 // formatQuery returns a formated graphql query by stracting struct's fields
-func formatQuery(query interface{}, vars interface{}) string {
-	queryVars, variables := formatVariables(vars)
+func formatQuery(query interface{}, vars interface{}, extra ...variable) string {
+	queryVars, variables := formatVariables(vars, extra...)
+
+	return fmt.Sprintf(`{"query":%s,%s}`,
+		encodeJSON(generateDocument(
+			utils.Ternary(queryVars != "", fmt.Sprintf("query(%s)", queryVars), "").(string),
+			query)),
+		variables)
+}
 
-	return fmt.Sprintf(`{"query":"%s{%s}",%s}`,
-		utils.Ternary(vars != nil, fmt.Sprintf("query(%s)", queryVars), ""),
-		generateQueryBody(query), variables)
+// queryBuilder generate the selections of the query structs
+// and collect the definitions of their named fragments.
+//
+// The graphql tag of a field has its arguments, or:
+//
+//	"inner"            the fields of the struct are selected in the parent
+//	"-"                the field is not selected
+//	"... on Type"      inline fragment, the struct is set if the object is a Type
+//	"fragment on Type" named fragment of the struct type, shared between queries,
+//	                   the struct type must be named
+//
+// A graphql-bind tag different from the field name selects the field with an alias,
+// so the same field can be selected with different arguments
+type queryBuilder struct {
+	fragments []string
+	// defined has the fragment name of each struct type, the types with the
+	// name of a type of another package get a numbered name
+	defined map[reflect.Type]string
+	names   map[string]bool
 }
 
-// parseField parse struct fields and generate the graphql query
-func parseField(field reflect.StructField) string {
+func newQueryBuilder() *queryBuilder {
+	return &queryBuilder{defined: map[reflect.Type]string{}, names: map[string]bool{}}
+}
+
+// body returns the selection of the query struct
+func (b *queryBuilder) body(query interface{}) string {
+	return b.selection(reflect.TypeOf(query))
+}
+
+// lowerFirst returns the name with the first letter in lower case
+func lowerFirst(name string) string {
+	return fmt.Sprintf("%s%s", string(bytes.ToLower([]byte{name[0]})), name[1:])
+}
+
+// responseName returns the key of the field in the response object
+func responseName(field reflect.StructField) string {
+	if bind, ok := field.Tag.Lookup("graphql-bind"); ok && strings.EqualFold(bind, field.Name) {
+		return bind
+	}
+
+	return lowerFirst(field.Name)
+}
+
+// fragmentCondition returns the type condition of inline and named fragments,
+// and if the fragment is named
+func fragmentCondition(field reflect.StructField) (string, bool, bool) {
+	tag := field.Tag.Get("graphql")
+
+	if strings.HasPrefix(tag, "... on ") {
+		return strings.TrimSpace(strings.TrimPrefix(tag, "... on ")), false, true
+	}
+
+	if strings.HasPrefix(tag, "fragment on ") {
+		return strings.TrimSpace(strings.TrimPrefix(tag, "fragment on ")), true, true
+	}
+
+	return "", false, false
+}
+
+// fragmentMarker returns the alias of the __typename selected in the fragments
+// on the type, the response object has it only if the fragment applied. Unlike
+// the __typename of the object it works for interface and union conditions
+func fragmentMarker(onType string) string {
+	return "_on" + onType
+}
+
+// elemType returns the struct type of pointers and slices
+func elemType(fieldType reflect.Type) reflect.Type {
+	for fieldType.Kind() == reflect.Ptr || fieldType.Kind() == reflect.Slice || fieldType.Kind() == reflect.Array {
+		fieldType = fieldType.Elem()
+	}
+
+	return fieldType
+}
+
+// field returns the selection of the struct field
+func (b *queryBuilder) field(field reflect.StructField) string {
+	if onType, named, ok := fragmentCondition(field); ok {
+		fragmentType := elemType(field.Type)
+
+		marker := fmt.Sprintf("%s:__typename,", fragmentMarker(onType))
+
+		if !named {
+			return fmt.Sprintf("... on %s{%s%s}", onType, marker, b.selection(fragmentType))
+		}
+
+		return fmt.Sprintf("...%s,", b.fragment(fragmentType, onType, marker))
+	}
+
 	q := ""
 
 	switch tag := field.Tag.Get("graphql"); tag {
 	case "inner":
-		return parseInnerFields(field.Type)
+		return b.selection(field.Type)
 	case "-":
 		return q
 	default:
 		if bind, ok := field.Tag.Lookup("graphql-bind"); ok {
+			if name := responseName(field); name != bind {
+				q += name + ":"
+			}
 			q += bind
 		} else {
-			q += fmt.Sprintf("%s%s", string(bytes.ToLower([]byte{field.Name[0]})), field.Name[1:])
+			q += lowerFirst(field.Name)
 		}
 		q += tag
 	}
 
-	switch field.Type.Kind() {
-	case reflect.Slice:
-		if field.Type.Elem().Kind() != reflect.Struct {
-			q += ","
-			break
-		}
-		q += fmt.Sprintf("{%s}", parseInnerFields(field.Type.Elem()))
-		break
-	case reflect.Ptr:
-		q += fmt.Sprintf("{%s}", parseInnerFields(field.Type.Elem()))
-		break
+	switch elem := elemType(field.Type); elem.Kind() {
 	case reflect.Struct:
-		q += fmt.Sprintf("{%s}", parseInnerFields(field.Type))
-		break
-
+		q += fmt.Sprintf("{%s}", b.selection(elem))
 	default:
 		q += ","
-		break
 	}
 
 	return q
 }
 
-// parseInnerFields parse fields inside a strut field
-func parseInnerFields(field reflect.Type) string {
+// fragment returns the name of the fragment of the struct type, the
+// definition is added the first time the type is selected.
+// Panics if the type has no name, the query struct is wrong
+func (b *queryBuilder) fragment(fragmentType reflect.Type, onType string, marker string) string {
+	if name, ok := b.defined[fragmentType]; ok {
+		return name
+	}
+
+	if fragmentType.Name() == "" {
+		panic(fmt.Sprintf(`the fragment on %s needs a named struct type, use "... on %s" for anonymous structs`, onType, onType))
+	}
+
+	name := fragmentType.Name()
+
+	for i := 2; b.names[name]; i++ {
+		name = fmt.Sprintf("%s%d", fragmentType.Name(), i)
+	}
+
+	b.defined[fragmentType] = name
+	b.names[name] = true
+	b.fragments = append(b.fragments, fmt.Sprintf("fragment %s on %s{%s%s}", name, onType, marker, b.selection(fragmentType)))
+
+	return name
+}
+
+// selection returns the selection of the fields of a struct
+func (b *queryBuilder) selection(structType reflect.Type) string {
+	structType = elemType(structType)
 	parsed := ""
-	for i := 0; i < field.NumField(); i++ {
-		parsed += parseField(field.Field(i))
+
+	for i := 0; i < structType.NumField(); i++ {
+		parsed += b.field(structType.Field(i))
 	}
+
 	return parsed
 }
 
-func formatVariables(vars interface{}) (string, string) {
-	q := ""
-	variables := `"variables":{`
+// parseField parse struct fields and generate the graphql query
+func parseField(field reflect.StructField) string {
+	return newQueryBuilder().field(field)
+}
 
-	if vars != nil {
+// parseInnerFields parse fields inside a strut field
+func parseInnerFields(field reflect.Type) string {
+	return newQueryBuilder().selection(field)
+}
+
+// variable is a graphql variable that is not a field of the variables struct
+type variable struct {
+	name    string
+	varType string
+	value   interface{}
+}
 
-		structValue := reflect.ValueOf(vars)
-		structType := reflect.TypeOf(vars)
+// formatVariables returns the variable declarations of the query
+// and the json of the variables field
+func formatVariables(vars interface{}, extra ...variable) (string, string) {
+	declarations := ""
+	var values []string
+
+	if vars != nil {
+		structValue := reflect.Indirect(reflect.ValueOf(vars))
+		structType := structValue.Type()
 
 		for i := 0; i < structType.NumField(); i++ {
 			field := structType.Field(i)
-			value := structValue.Field(i)
-
-			name := fmt.Sprintf("%s%s", string(bytes.ToLower([]byte{field.Name[0]})), field.Name[1:])
-
-			q += fmt.Sprintf("$%s:", name)
-			variables += fmt.Sprintf(`"%s":`, name)
-
-			var varType string
-			var varValue string
-
-			switch value.Kind() {
-			case reflect.Array, reflect.Slice:
-				varType = fmt.Sprintf("[%s]", parseType(value.Kind()))
-				varValue = fmt.Sprintf("%v", value)
-				break
-			case reflect.String:
-				varType = "String,"
-				if val := fmt.Sprintf("%s", value); val == "null" {
-					varValue = val
-					break
-				}
-				varValue = fmt.Sprintf(`"%s"`, value)
-				break
-			default:
-				varType = parseType(value.Kind())
-				varValue = fmt.Sprintf("%v", value)
-				break
-			}
+			name := variableName(field)
 
-			if fieldType, ok := field.Tag.Lookup("graphql-type"); ok {
-				varType = fieldType + ","
-			}
+			varType, ok := field.Tag.Lookup("graphql-type")
 
-			if i != structType.NumField()-1 {
-				varValue += ","
+			if !ok {
+				varType = variableType(field.Type)
 			}
 
-			q += varType
-			variables += varValue
+			declarations += fmt.Sprintf("$%s:%s,", name, varType)
+			values = append(values, fmt.Sprintf("%s:%s", encodeJSON(name), encodeJSON(variableValue(structValue.Field(i)))))
 		}
 	}
 
-	variables += "}"
-	return q, variables
+	for _, v := range extra {
+		declarations += fmt.Sprintf("$%s:%s,", v.name, v.varType)
+		values = append(values, fmt.Sprintf("%s:%s", encodeJSON(v.name), encodeJSON(v.value)))
+	}
+
+	return declarations, fmt.Sprintf(`"variables":{%s}`, strings.Join(values, ","))
+}
+
+// encodeJSON returns the json of the value without escaping html characters
+func encodeJSON(value interface{}) string {
+	buffer := &bytes.Buffer{}
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)
+
+	if err := encoder.Encode(value); err != nil {
+		return "null"
+	}
+
+	return strings.TrimSuffix(buffer.String(), "\n")
+}
+
+// variableName returns the graphql name of a variable or input object field
+func variableName(field reflect.StructField) string {
+	if bind, ok := field.Tag.Lookup("graphql-bind"); ok {
+		return bind
+	}
+
+	return fmt.Sprintf("%s%s", string(bytes.ToLower([]byte{field.Name[0]})), field.Name[1:])
+}
+
+// variableType returns the graphql type of a variable without graphql-type tag,
+// named structs are input objects of the same name.
+// Maps have no graphql type, they need the tag
+func variableType(varType reflect.Type) string {
+	switch varType.Kind() {
+	case reflect.Ptr:
+		return variableType(varType.Elem())
+	case reflect.Array, reflect.Slice:
+		return fmt.Sprintf("[%s]", variableType(varType.Elem()))
+	case reflect.Struct:
+		return varType.Name()
+	}
+
+	return parseType(varType.Kind())
 }
 
-func parseArray(kind reflect.Kind) string {
-	return fmt.Sprintf("[%s]", parseType(kind))
+// variableValue converts the variable to a value encoded by encoding/json,
+// the unexported fields of the variables structs can not be encoded directly.
+// Nil pointers are sent as null
+func variableValue(value reflect.Value) interface{} {
+	if value.Kind() != reflect.Ptr && value.CanInterface() {
+		if marshaler, ok := value.Interface().(json.Marshaler); ok {
+			return marshaler
+		}
+	}
+
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			return nil
+		}
+		return variableValue(value.Elem())
+	case reflect.String:
+		return value.String()
+	case reflect.Bool:
+		return value.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return value.Uint()
+	case reflect.Float32, reflect.Float64:
+		return value.Float()
+	case reflect.Array, reflect.Slice:
+		list := make([]interface{}, value.Len())
+
+		for i := range list {
+			list[i] = variableValue(value.Index(i))
+		}
+
+		return list
+	case reflect.Map:
+		if value.IsNil() {
+			return nil
+		}
+
+		object := make(map[string]interface{}, value.Len())
+		iter := value.MapRange()
+
+		for iter.Next() {
+			object[fmt.Sprint(iter.Key())] = variableValue(iter.Value())
+		}
+
+		return object
+	case reflect.Struct:
+		object := make(map[string]interface{}, value.NumField())
+
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+
+			if field.Tag.Get("graphql") == "-" {
+				continue
+			}
+
+			object[variableName(field)] = variableValue(value.Field(i))
+		}
+
+		return object
+	}
+
+	return nil
 }
 
 func parseType(kind reflect.Kind) string {
 	switch kind {
 	case reflect.Int, reflect.Uint, reflect.Int8, reflect.Uint8, reflect.Int16, reflect.Uint16,
 		reflect.Int32, reflect.Uint32, reflect.Int64, reflect.Uint64:
-		return "Int,"
+		return "Int"
 	case reflect.String:
-		return "String,"
+		return "String"
 	case reflect.Bool:
-		return "Boolean,"
+		return "Boolean"
 	case reflect.Float32, reflect.Float64:
-		return "Float,"
+		return "Float"
 	}
 	return ""
 }
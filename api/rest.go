@@ -2,12 +2,17 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"net/http"
 )
 
-func restReq(method string, path string, data []byte) (*http.Request, error) {
-	req, err := http.NewRequest(method, fmt.Sprintf("%s/%s", rest, path), bytes.NewBuffer(data))
+func (c *Client) restReq(ctx context.Context, method string, path string, data []byte) (*http.Request, error) {
+	if err := c.allowed(path); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint(fmt.Sprintf("%s/%s", rest, path)), bytes.NewBuffer(data))
 
 	if err != nil {
 		return nil, err
@@ -19,36 +24,46 @@ func restReq(method string, path string, data []byte) (*http.Request, error) {
 // Post send post request to gitlan api v4
 // and bind the response in the given bind parameter
 func (c *Client) Post(path string, data []byte, bind interface{}) error {
-	req, err := restReq(post, path, data)
+	return c.PostContext(context.Background(), path, data, bind)
+}
 
-	if err != nil {
+// PostContext send post request to gitlab api v4, canceled with the given context
+func (c *Client) PostContext(ctx context.Context, path string, data []byte, bind interface{}) error {
+	if err := c.RequireScope(WriteScope); err != nil {
 		return err
 	}
 
-	bytes, err := c.send(req)
+	req, err := c.restReq(ctx, post, path, data)
+
 	if err != nil {
 		return err
 	}
 
-	bindRestResponse(bytes, bind)
+	bytes, _, err := c.send(req, false)
+	if err != nil {
+		return err
+	}
 
-	return nil
+	return bindRestResponse(bytes, bind)
 }
 
 // Get send get request to gitlab api v4
 // and bind the response in the given bind parameter
 func (c *Client) Get(path string, bind interface{}) error {
-	req, err := restReq(get, path, nil)
+	return c.GetContext(context.Background(), path, bind)
+}
+
+// GetContext send get request to gitlab api v4, canceled with the given context
+func (c *Client) GetContext(ctx context.Context, path string, bind interface{}) error {
+	req, err := c.restReq(ctx, get, path, nil)
 	if err != nil {
 		return err
 	}
 
-	bytes, err := c.send(req)
+	bytes, _, err := c.send(req, true)
 	if err != nil {
 		return err
 	}
 
-	bindRestResponse(bytes, bind)
-
-	return nil
+	return bindRestResponse(bytes, bind)
 }
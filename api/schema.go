@@ -0,0 +1,210 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// introspectionQuery request the types of the schema with their fields,
+// arguments and input fields, the type references are nested to the
+// same depth as the standard introspection query
+const introspectionQuery = `query IntrospectionQuery {
+  __schema {
+    queryType { name }
+    mutationType { name }
+    types {
+      kind
+      name
+      fields(includeDeprecated: true) {
+        name
+        args { name type { ...TypeRef } defaultValue }
+        type { ...TypeRef }
+      }
+      inputFields { name type { ...TypeRef } defaultValue }
+      interfaces { ...TypeRef }
+      enumValues(includeDeprecated: true) { name }
+      possibleTypes { ...TypeRef }
+    }
+  }
+}
+
+fragment TypeRef on __Type {
+  kind
+  name
+  ofType {
+    kind
+    name
+    ofType {
+      kind
+      name
+      ofType {
+        kind
+        name
+        ofType {
+          kind
+          name
+          ofType {
+            kind
+            name
+            ofType {
+              kind
+              name
+              ofType {
+                kind
+                name
+              }
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+
+// Kinds of the schema types
+const (
+	scalarKind      = "SCALAR"
+	objectKind      = "OBJECT"
+	interfaceKind   = "INTERFACE"
+	unionKind       = "UNION"
+	enumKind        = "ENUM"
+	inputObjectKind = "INPUT_OBJECT"
+	listKind        = "LIST"
+	nonNullKind     = "NON_NULL"
+)
+
+// TypeRef is the type of a field, argument or variable
+type TypeRef struct {
+	Kind   string   `json:"kind"`
+	Name   string   `json:"name,omitempty"`
+	OfType *TypeRef `json:"ofType,omitempty"`
+}
+
+func (t *TypeRef) String() string {
+	switch t.Kind {
+	case nonNullKind:
+		return t.OfType.String() + "!"
+	case listKind:
+		return "[" + t.OfType.String() + "]"
+	}
+
+	return t.Name
+}
+
+// InputValue is an argument of a field or a field of an input object
+type InputValue struct {
+	Name         string  `json:"name"`
+	Type         TypeRef `json:"type"`
+	DefaultValue *string `json:"defaultValue"`
+}
+
+// SchemaField is a field of an object or interface type
+type SchemaField struct {
+	Name string       `json:"name"`
+	Args []InputValue `json:"args"`
+	Type TypeRef      `json:"type"`
+}
+
+// EnumValue is a value of an enum type
+type EnumValue struct {
+	Name string `json:"name"`
+}
+
+// SchemaType is a type of the schema
+type SchemaType struct {
+	Kind          string        `json:"kind"`
+	Name          string        `json:"name"`
+	Fields        []SchemaField `json:"fields"`
+	InputFields   []InputValue  `json:"inputFields"`
+	Interfaces    []TypeRef     `json:"interfaces"`
+	EnumValues    []EnumValue   `json:"enumValues"`
+	PossibleTypes []TypeRef     `json:"possibleTypes"`
+}
+
+// Schema is the graphql schema of a gitlab instance, as returned by
+// the introspection query. It validates the queries generated by the
+// query structs before they are sent
+type Schema struct {
+	QueryType struct {
+		Name string `json:"name"`
+	} `json:"queryType"`
+	MutationType struct {
+		Name string `json:"name"`
+	} `json:"mutationType"`
+	Types []SchemaType `json:"types"`
+
+	definitions *ast.Schema
+}
+
+// ParseSchema parse the result of the introspection query,
+// with or without the data field of the response
+func ParseSchema(data []byte) (*Schema, error) {
+	var introspection struct {
+		Data struct {
+			Schema *Schema `json:"__schema"`
+		} `json:"data"`
+		Schema *Schema `json:"__schema"`
+	}
+
+	if err := json.Unmarshal(data, &introspection); err != nil {
+		return nil, err
+	}
+
+	schema := introspection.Schema
+
+	if schema == nil {
+		schema = introspection.Data.Schema
+	}
+
+	if schema == nil {
+		return nil, fmt.Errorf("the introspection result has no __schema")
+	}
+
+	if err := schema.loadDefinitions(); err != nil {
+		return nil, err
+	}
+
+	return schema, nil
+}
+
+// LoadSchema read the introspection result in the given file
+func LoadSchema(path string) (*Schema, error) {
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseSchema(data)
+}
+
+// Introspect request the schema of the instance, and returns the
+// introspection response unmodified, after checking that it can be parsed
+func (c *Client) Introspect(ctx context.Context) ([]byte, error) {
+	req, err := c.graphqlReq(ctx, strings.NewReader(fmt.Sprintf(`{"query":%s}`, encodeJSON(introspectionQuery))))
+
+	if err != nil {
+		return nil, err
+	}
+
+	body, _, err := c.send(req, true)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := bindGraphqlResponse(body, nil); err != nil {
+		return nil, err
+	}
+
+	if _, err := ParseSchema(body); err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
@@ -0,0 +1,223 @@
+package api
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/angel-afonso/gitlabcli/auth"
+)
+
+func TestValidate(t *testing.T) {
+	schema, err := LoadSchema("schema.json")
+
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	type pipelines struct {
+		Nodes []struct {
+			DetailedStatus struct {
+				Label string
+			}
+		}
+	}
+
+	var valid struct {
+		Project struct {
+			Name      string
+			Pipelines pipelines `graphql:"(first: 1, ref: \"master\", status: SUCCESS)"`
+		} `graphql:"(fullPath: $path)"`
+	}
+
+	assert.NoError(t, schema.ValidateQuery(&valid, struct {
+		path string `graphql-type:"ID!"`
+	}{}))
+
+	var invalid struct {
+		Project struct {
+			Name      string
+			Pipeline  pipelines `graphql:"(first: 1)"`
+			Pipelines pipelines `graphql:"(branch: \"master\", status: DONE)"`
+			Issues    struct {
+				Count int
+			} `graphql:"(state: $state)"`
+			Releases struct {
+				Nodes []struct {
+					TagName struct{ Name string }
+				}
+			}
+		} `graphql:"(fullPath: $path)"`
+	}
+
+	err = schema.ValidateQuery(&invalid, struct {
+		path   string
+		state  string `graphql-type:"IssueState"`
+		unused int
+	}{})
+
+	assert.EqualError(t, err, `Variable "$path" of type "String" used in position expecting type "ID!".
+Cannot query field "pipeline" on type "Project". Did you mean "pipelines"?
+Expected type PipelineStatusEnum, found DONE.
+Unknown argument "branch" on field "pipelines" of type "Project".
+Variable "$state" of type "IssueState" used in position expecting type "IssuableState".
+Cannot query field "name" on type "String".
+Field "tagName" must not have a selection since type "String" has no subfields.
+Variable "$unused" is never used.`)
+}
+
+func TestValidateMutation(t *testing.T) {
+	schema, err := LoadSchema("schema.json")
+
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var mutation struct {
+		MergeRequestCreate struct {
+			MergeRequest struct {
+				Iid string
+			}
+			Errors []string
+		} `graphql:"(input:{title:$title,projectPath:$path,branch:$source})"`
+	}
+
+	err = schema.ValidateMutation(&mutation, struct {
+		path   string `graphql-type:"ID!"`
+		title  string `graphql-type:"String!"`
+		source string `graphql-type:"String!"`
+	}{})
+
+	assert.EqualError(t, err, `Field MergeRequestCreateInput.sourceBranch of required type String! was not provided.
+Field MergeRequestCreateInput.targetBranch of required type String! was not provided.
+Field "branch" is not defined by type MergeRequestCreateInput. Did you mean sourceBranch or targetBranch?`)
+}
+
+// TestValidateRenamed checks the fields and arguments gitlab renamed when
+// work in progress merge requests became drafts
+func TestValidateRenamed(t *testing.T) {
+	schema, err := LoadSchema("schema.json")
+
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var query struct {
+		Project struct {
+			MergeRequest struct {
+				Draft          bool
+				WorkInProgress bool
+			} `graphql:"(iid: $iid)"`
+		} `graphql:"(fullPath: $path)"`
+	}
+
+	err = schema.ValidateQuery(&query, struct {
+		path string `graphql-type:"ID!"`
+		iid  string `graphql-type:"String!"`
+	}{})
+
+	assert.EqualError(t, err, `Cannot query field "workInProgress" on type "MergeRequest".`)
+
+	var draft struct {
+		MergeRequestSetDraft struct {
+			Errors []string
+		} `graphql:"(input:{projectPath:$path,iid:$iid,draft:true})"`
+	}
+
+	var wip struct {
+		MergeRequestSetDraft struct {
+			Errors []string
+		} `graphql:"(input:{projectPath:$path,iid:$iid,wip:true})"`
+		MergeRequestSetWip struct {
+			Errors []string
+		} `graphql:"(input:{projectPath:$path,iid:$iid,wip:true})"`
+	}
+
+	variables := struct {
+		path string `graphql-type:"ID!"`
+		iid  string `graphql-type:"String!"`
+	}{}
+
+	assert.NoError(t, schema.ValidateMutation(&draft, variables))
+
+	err = schema.ValidateMutation(&wip, variables)
+
+	assert.EqualError(t, err, `Field MergeRequestSetDraftInput.draft of required type Boolean! was not provided.
+Field "wip" is not defined by type MergeRequestSetDraftInput.
+Cannot query field "mergeRequestSetWip" on type "Mutation". Did you mean "mergeRequestSetDraft", "mergeRequestCreate", or "mergeRequestSetAssignees"?`)
+}
+
+func TestParseSchemaAbstractTypes(t *testing.T) {
+	schema, err := ParseSchema([]byte(`{"__schema":{"queryType":{"name":"Query"},"mutationType":null,"types":[
+		{"kind":"OBJECT","name":"Query","fields":[
+			{"name":"todos","args":[
+				{"name":"first","type":{"kind":"SCALAR","name":"Int"},"defaultValue":"20"},
+				{"name":"state","type":{"kind":"NON_NULL","ofType":{"kind":"ENUM","name":"TodoState"}},"defaultValue":"pending"}
+			],"type":{"kind":"LIST","ofType":{"kind":"OBJECT","name":"Todo"}}}
+		]},
+		{"kind":"ENUM","name":"TodoState","enumValues":[{"name":"pending"},{"name":"done"}]},
+		{"kind":"OBJECT","name":"Todo","fields":[{"name":"target","args":[],"type":{"kind":"UNION","name":"Target"}}]},
+		{"kind":"UNION","name":"Target","possibleTypes":[{"kind":"OBJECT","name":"Issue"},{"kind":"OBJECT","name":"Epic"}]},
+		{"kind":"INTERFACE","name":"Noteable","fields":[{"name":"webUrl","args":[],"type":{"kind":"SCALAR","name":"String"}}]},
+		{"kind":"OBJECT","name":"Issue","interfaces":[{"kind":"INTERFACE","name":"Noteable"}],"fields":[
+			{"name":"iid","args":[],"type":{"kind":"SCALAR","name":"String"}},
+			{"name":"webUrl","args":[],"type":{"kind":"SCALAR","name":"String"}}
+		]},
+		{"kind":"OBJECT","name":"Epic","fields":[{"name":"iid","args":[],"type":{"kind":"SCALAR","name":"String"}}]},
+		{"kind":"SCALAR","name":"String"},
+		{"kind":"OBJECT","name":"__Type","fields":[{"name":"name","args":[],"type":{"kind":"SCALAR","name":"String"}}]}
+	]}}`))
+
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var query struct {
+		Todos []struct {
+			Target struct {
+				Noteable *struct {
+					WebURL string `graphql-bind:"webUrl"`
+				} `graphql:"... on Noteable"`
+				Epic *struct {
+					Iid string
+				} `graphql:"... on Epic"`
+			}
+		}
+	}
+
+	assert.NoError(t, schema.ValidateQuery(&query, nil))
+
+	var invalid struct {
+		Todos []struct {
+			Target struct {
+				Iid string
+			}
+		} `graphql:"(state: closed)"`
+	}
+
+	assert.EqualError(t, schema.ValidateQuery(&invalid, nil), `Expected type TodoState!, found closed.
+Cannot query field "iid" on type "Target". Did you mean to use an inline fragment on "Issue" or "Epic"?`)
+}
+
+func TestIntrospect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.Contains(t, string(body), "IntrospectionQuery")
+		w.Write([]byte(`{"data":{"__schema":{"queryType":{"name":"Query"},"mutationType":null,"types":[{"kind":"OBJECT","name":"Query","fields":[{"name":"version","args":[],"type":{"kind":"SCALAR","name":"String"}}]}]}}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(&auth.Session{Token: "secret", Type: auth.PrivateToken, Host: server.URL})
+
+	data, err := client.Introspect(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, `{"data":{"__schema":{"queryType":{"name":"Query"},"mutationType":null,"types":[{"kind":"OBJECT","name":"Query","fields":[{"name":"version","args":[],"type":{"kind":"SCALAR","name":"String"}}]}]}}}`, string(data))
+
+	schema, err := ParseSchema(data)
+	assert.NoError(t, err)
+	assert.Equal(t, "Query", schema.QueryType.Name)
+	assert.Contains(t, schema.definitions.Types, "Query")
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"fmt"
+
+	"gitlab.com/angel-afonso/gitlabcli/auth"
+)
+
+// WriteScope is the token scope required by mutations
+const WriteScope = "api"
+
+// ScopeError is returned when the token lacks the scope required by a request
+type ScopeError struct {
+	Scope string
+}
+
+func (e *ScopeError) Error() string {
+	return fmt.Sprintf("this command needs the `%s` scope, login again with a token that has it: gitlabcli auth login", e.Scope)
+}
+
+// Scopes returns the scopes of the session token, they are requested once
+// and cached in the session profile. Returns nil when the scopes are unknown
+func (c *Client) Scopes() []string {
+	if c.session.Scopes != nil || c.scopesRequested {
+		return c.session.Scopes
+	}
+
+	c.scopesRequested = true
+
+	var scopes []string
+
+	switch c.session.Type {
+	case auth.JobToken:
+		return nil
+	case auth.PrivateToken:
+		var token struct {
+			Scopes []string
+		}
+
+		if err := c.Get("personal_access_tokens/self", &token); err != nil {
+			return nil
+		}
+
+		scopes = token.Scopes
+	default:
+		info, err := auth.Inspect(c.session)
+
+		if err != nil {
+			return nil
+		}
+
+		scopes = info.Scopes
+	}
+
+	auth.SaveScopes(c.session, scopes)
+	return scopes
+}
+
+// RequireScope returns a ScopeError if the scopes of the token are known
+// and the given scope is not granted
+func (c *Client) RequireScope(scope string) error {
+	c.Scopes()
+
+	if !c.session.HasScope(scope) {
+		return &ScopeError{Scope: scope}
+	}
+
+	return nil
+}
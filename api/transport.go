@@ -0,0 +1,88 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"gitlab.com/angel-afonso/gitlabcli/config"
+)
+
+// HTTPClient sends the api requests, they share its transport connections
+var HTTPClient = &http.Client{Transport: baseTransport()}
+
+// baseTransport returns a transport that keeps the connections to the instance alive
+func baseTransport() *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// NewTransport returns a transport with the proxy, certificate authorities
+// and client certificates of the given config
+func NewTransport(cfg config.Config) (*http.Transport, error) {
+	transport := baseTransport()
+
+	if cfg.Proxy != "" {
+		proxy, err := url.Parse(cfg.Proxy)
+
+		if err != nil || proxy.Host == "" {
+			return nil, fmt.Errorf("invalid proxy url %s", cfg.Proxy)
+		}
+
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+
+	if len(cfg.CAFiles) > 0 {
+		pool, err := x509.SystemCertPool()
+
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		for _, file := range cfg.CAFiles {
+			pem, err := ioutil.ReadFile(file)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in %s", file)
+			}
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	for _, pair := range cfg.ClientCertificates {
+		cert, err := tls.LoadX509KeyPair(pair.Cert, pair.Key)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate %s: %s", pair.Cert, err.Error())
+		}
+
+		tlsConfig.Certificates = append(tlsConfig.Certificates, cert)
+	}
+
+	transport.TLSClientConfig = tlsConfig
+
+	return transport, nil
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/angel-afonso/gitlabcli/config"
+)
+
+// writePEM write the pem blocks in a file of the given directory
+func writePEM(t *testing.T, dir string, name string, blockType string, bytes []byte) string {
+	file := path.Join(dir, name)
+
+	if err := ioutil.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: bytes}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return file
+}
+
+// clientCertificate generate a self signed client certificate
+func clientCertificate(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "gitlabcli"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert, key
+}
+
+// tempDir returns a directory removed after the test
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "gitlabcli")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+// tlsGet send a get request with a transport of the given config
+func tlsGet(t *testing.T, cfg config.Config, url string) error {
+	transport, err := NewTransport(cfg)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := (&http.Client{Transport: transport}).Get(url)
+
+	if err != nil {
+		return err
+	}
+
+	resp.Body.Close()
+	return nil
+}
+
+func TestTransportCAFiles(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+
+	dir := tempDir(t)
+	ca := writePEM(t, dir, "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+
+	assert.Error(t, tlsGet(t, config.Config{}, server.URL))
+	assert.NoError(t, tlsGet(t, config.Config{CAFiles: []string{ca}}, server.URL))
+	assert.NoError(t, tlsGet(t, config.Config{InsecureSkipVerify: true}, server.URL))
+
+	_, err := NewTransport(config.Config{CAFiles: []string{path.Join(dir, "missing.pem")}})
+	assert.Error(t, err)
+}
+
+func TestTransportClientCertificates(t *testing.T) {
+	cert, key := clientCertificate(t)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(cert)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "gitlabcli", r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := tempDir(t)
+	pair := config.KeyPair{
+		Cert: writePEM(t, dir, "client.pem", "CERTIFICATE", cert.Raw),
+		Key:  writePEM(t, dir, "client-key.pem", "EC PRIVATE KEY", keyDER),
+	}
+
+	assert.Error(t, tlsGet(t, config.Config{InsecureSkipVerify: true}, server.URL))
+	assert.NoError(t, tlsGet(t, config.Config{InsecureSkipVerify: true, ClientCertificates: []config.KeyPair{pair}}, server.URL))
+}
+
+func TestTransportProxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "gitlab.example.com", r.URL.Host)
+		w.Write([]byte(`{"username":"root"}`))
+	}))
+	defer proxy.Close()
+
+	assert.NoError(t, tlsGet(t, config.Config{Proxy: proxy.URL}, "http://gitlab.example.com/api/v4/user"))
+
+	_, err := NewTransport(config.Config{Proxy: "not a url"})
+	assert.Error(t, err)
+}
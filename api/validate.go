@@ -0,0 +1,174 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/parser"
+	"github.com/vektah/gqlparser/v2/validator"
+)
+
+// ValidationError is returned with every problem of a query
+// that does not match the schema
+type ValidationError struct {
+	// Document is the graphql document that was validated
+	Document string
+	Errors   []string
+}
+
+func (e *ValidationError) Error() string {
+	return strings.Join(e.Errors, "\n")
+}
+
+// ValidateQuery check the query generated by the query struct and its variables
+// against the schema with the validation rules of the graphql specification
+func (s *Schema) ValidateQuery(query interface{}, variables interface{}) error {
+	return s.validate(formatQuery(query, variables))
+}
+
+// ValidatePaginated check the query of a paginator, with the
+// $first and $after variables added by the paginator
+func (s *Schema) ValidatePaginated(query interface{}, variables interface{}) error {
+	return s.validate(formatQuery(query, variables, pageVariables(DefaultPageSize, nil)...))
+}
+
+// ValidateMutation check the mutation generated by the struct and its variables against the schema
+func (s *Schema) ValidateMutation(mutation interface{}, variables interface{}) error {
+	return s.validate(formatMutation(mutation, variables))
+}
+
+// validate parse the document of the request body and check it against the schema
+func (s *Schema) validate(body string) error {
+	var request struct {
+		Query string
+	}
+
+	if err := bindRestResponse([]byte(body), &request); err != nil {
+		return err
+	}
+
+	document, err := parser.ParseQuery(&ast.Source{Input: request.Query})
+
+	if err != nil {
+		return &ValidationError{Document: request.Query, Errors: []string{err.Message}}
+	}
+
+	errs := validator.Validate(s.definitions, document)
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	messages := make([]string, len(errs))
+
+	for i, err := range errs {
+		messages[i] = err.Message
+	}
+
+	return &ValidationError{Document: request.Query, Errors: messages}
+}
+
+// builtinScalars are declared by the prelude of the validator
+var builtinScalars = map[string]bool{"String": true, "Int": true, "Float": true, "Boolean": true, "ID": true}
+
+// loadDefinitions load the types of the introspection result in the validator,
+// the introspection types and the built-in scalars come from its prelude
+func (s *Schema) loadDefinitions() error {
+	var sdl strings.Builder
+
+	fmt.Fprintf(&sdl, "schema{query:%s", s.QueryType.Name)
+
+	if s.MutationType.Name != "" {
+		fmt.Fprintf(&sdl, " mutation:%s", s.MutationType.Name)
+	}
+
+	sdl.WriteString("}\n")
+
+	for _, t := range s.Types {
+		if strings.HasPrefix(t.Name, "__") || builtinScalars[t.Name] {
+			continue
+		}
+
+		switch t.Kind {
+		case scalarKind:
+			fmt.Fprintf(&sdl, "scalar %s\n", t.Name)
+		case objectKind, interfaceKind:
+			keyword := "type"
+
+			if t.Kind == interfaceKind {
+				keyword = "interface"
+			}
+
+			fmt.Fprintf(&sdl, "%s %s", keyword, t.Name)
+
+			if t.Kind == objectKind && len(t.Interfaces) > 0 {
+				names := make([]string, len(t.Interfaces))
+
+				for i, implemented := range t.Interfaces {
+					names[i] = implemented.Name
+				}
+
+				fmt.Fprintf(&sdl, " implements %s", strings.Join(names, " & "))
+			}
+
+			sdl.WriteString("{\n")
+
+			for _, field := range t.Fields {
+				sdl.WriteString(field.Name)
+
+				if len(field.Args) > 0 {
+					fmt.Fprintf(&sdl, "(%s)", inputValues(field.Args, ","))
+				}
+
+				fmt.Fprintf(&sdl, ":%s\n", field.Type.String())
+			}
+
+			sdl.WriteString("}\n")
+		case unionKind:
+			names := make([]string, len(t.PossibleTypes))
+
+			for i, possible := range t.PossibleTypes {
+				names[i] = possible.Name
+			}
+
+			fmt.Fprintf(&sdl, "union %s=%s\n", t.Name, strings.Join(names, "|"))
+		case enumKind:
+			names := make([]string, len(t.EnumValues))
+
+			for i, value := range t.EnumValues {
+				names[i] = value.Name
+			}
+
+			fmt.Fprintf(&sdl, "enum %s{%s}\n", t.Name, strings.Join(names, " "))
+		case inputObjectKind:
+			fmt.Fprintf(&sdl, "input %s{\n%s\n}\n", t.Name, inputValues(t.InputFields, "\n"))
+		}
+	}
+
+	definitions, err := gqlparser.LoadSchema(&ast.Source{Name: "schema", Input: sdl.String()})
+
+	if err != nil {
+		return fmt.Errorf("the schema can not be loaded: %s", err.Message)
+	}
+
+	s.definitions = definitions
+	return nil
+}
+
+// inputValues returns the declarations of arguments or input fields,
+// the default values are graphql literals in the introspection result
+func inputValues(values []InputValue, separator string) string {
+	declarations := make([]string, len(values))
+
+	for i, value := range values {
+		declarations[i] = fmt.Sprintf("%s:%s", value.Name, value.Type.String())
+
+		if value.DefaultValue != nil {
+			declarations[i] += "=" + *value.DefaultValue
+		}
+	}
+
+	return strings.Join(declarations, separator)
+}
@@ -1,17 +1,13 @@
 package auth
 
 import (
-	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"net/http"
 	"os"
-	"os/exec"
 	"path"
-	"runtime"
+	"strings"
+	"time"
 
-	"gitlab.com/angel-afonso/gitlabcli/utils"
 	"go.etcd.io/bbolt"
 
 	color "gopkg.in/gookit/color.v1"
@@ -20,176 +16,444 @@ import (
 const (
 	applicationid = "fa19a133b14bbcaf20a6e5bf6a4e5666cbdf19d0e8ad4f106ba3dea235a1e16b"
 	callback      = "http://localhost:7890"
+	callbackPort  = 7890
+
+	// DefaultHost is the gitlab instance used when no host is configured
+	DefaultHost = "gitlab.com"
+
+	// PrivateToken is the session type of personal access tokens
+	PrivateToken = "private-token"
+	// JobToken is the session type of gitlab ci job tokens
+	JobToken = "job-token"
 )
 
 // Session has a bbolt db with the authentication data
 type Session struct {
-	Token string
-	Type  string
+	Profile      string
+	Token        string
+	Type         string
+	Host         string
+	RefreshToken string
+	Expiry       time.Time
+	// Scopes granted to the token, nil when unknown
+	Scopes []string
+	// Redirect is the redirect uri of the oauth login, sent again on refresh
+	Redirect string
+}
+
+// HasScope returns true if the token has the scope, or if its scopes are unknown
+func (s *Session) HasScope(scope string) bool {
+	if len(s.Scopes) == 0 {
+		return true
+	}
+
+	for _, granted := range s.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SaveScopes store the scopes of the session token in its profile
+func SaveScopes(session *Session, scopes []string) error {
+	session.Scopes = scopes
+	return saveProfile(session)
+}
+
+// Expired returns true if the access token is expired or about to expire
+func (s *Session) Expired() bool {
+	return !s.Expiry.IsZero() && time.Now().Add(30*time.Second).After(s.Expiry)
+}
+
+// BaseURL returns the instance url of the session host
+func (s *Session) BaseURL() string {
+	return BaseURL(s.Host)
+}
+
+// BaseURL returns the url of the given gitlab host.
+// Hosts without scheme are served over https
+func BaseURL(host string) string {
+	if host == "" {
+		host = DefaultHost
+	}
+
+	host = strings.TrimRight(host, "/")
+
+	if strings.Contains(host, "://") {
+		return host
+	}
+
+	return "https://" + host
 }
 
-// OpenSession opens session database and returns session struct
-func OpenSession() *Session {
+// clientID returns the oauth application id,
+// self-hosted instances must register their own application
+func clientID() string {
+	if id := os.Getenv("GITLAB_CLIENT_ID"); id != "" {
+		return id
+	}
+	return applicationid
+}
+
+// openDB opens the session database, creating the config directory if needed
+func openDB() (*bbolt.DB, error) {
 	homeDir, _ := os.UserHomeDir()
 	glPath := path.Join(homeDir, ".gitlabcli")
 
 	if _, err := os.Stat(glPath); os.IsNotExist(err) {
 		os.Mkdir(glPath, 0700)
 	} else if err != nil {
-		color.Red.Println(err.Error())
-		os.Exit(1)
+		return nil, err
 	}
 
-	db, err := bbolt.Open(path.Join(glPath, "session"), 0600, nil)
+	db, err := bbolt.Open(path.Join(glPath, "session"), 0600, &bbolt.Options{Timeout: time.Second})
+
+	if err == bbolt.ErrTimeout {
+		return nil, errors.New("the session database is locked by another gitlabcli process")
+	}
+
+	return db, err
+}
+
+// sessionFromEnv build a session with the GITLAB_TOKEN or CI_JOB_TOKEN
+// environment variables, returns nil if none is set
+func sessionFromEnv(host string) *Session {
+	if host == "" {
+		host = os.Getenv("CI_SERVER_URL")
+	}
+
+	if host == "" {
+		host = DefaultHost
+	}
+
+	if token := os.Getenv("GITLAB_TOKEN"); token != "" {
+		return &Session{Token: token, Type: PrivateToken, Host: host}
+	}
+
+	if token := os.Getenv("CI_JOB_TOKEN"); token != "" {
+		return &Session{Token: token, Type: JobToken, Host: host}
+	}
+
+	return nil
+}
+
+// Options select the stored profile of a session
+type Options struct {
+	// Host of the gitlab instance, an empty host uses the active profile
+	Host string
+	// Profile name, an empty name uses the active profile or the profile of the host
+	Profile string
+	// Remote is the host of the repository remote, it selects the profile
+	// of that host only if one is stored, the remote may not be a gitlab instance
+	Remote string
+}
+
+// profile returns the profile name used to store a new session
+func (o Options) profile() string {
+	if o.Profile != "" {
+		return o.Profile
+	}
+	return o.host()
+}
+
+func (o Options) host() string {
+	if o.Host != "" {
+		return o.Host
+	}
+	return DefaultHost
+}
+
+// ErrNoSession is returned when there is no stored session for the selected profile
+var ErrNoSession = errors.New("not logged in, login with gitlabcli auth login")
+
+// LookUp returns the session selected by the given options without login.
+// Credentials in the environment take precedence over the session database
+func LookUp(options Options) (*Session, error) {
+	if session := sessionFromEnv(options.Host); session != nil {
+		return session, nil
+	}
+
+	db, err := openDB()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer db.Close()
+
+	return lookUpSession(db, options)
+}
+
+// OpenSession returns the session selected by the given options,
+// login is required if there is no stored profile
+func OpenSession(options Options) *Session {
+	session, err := LookUp(options)
+
+	if err == ErrNoSession {
+		session, err = Login(options)
+	}
 
 	if err != nil {
 		color.Red.Println(err.Error())
 		os.Exit(1)
 	}
 
-	session, err := lookUpSession(db)
+	return session
+}
+
+// Login opens the browser to authenticate with the given host,
+// the session is stored in the selected profile and made active
+func Login(options Options) (*Session, error) {
+	return loginWith(options, login)
+}
+
+// LoginWithDevice authenticate with the device authorization grant,
+// for machines without browser
+func LoginWithDevice(options Options) (*Session, error) {
+	return loginWith(options, deviceLogin)
+}
+
+// loginWith request a token with the given flow and store it
+func loginWith(options Options, flow func(host string) (*token, error)) (*Session, error) {
+	options, err := remoteProfile(options)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkProfileName(options.profile()); err != nil {
+		return nil, err
+	}
+
+	data, err := flow(options.host())
 
 	if err != nil {
-		session = storeToken(db, login())
+		return nil, err
 	}
 
-	return session
+	db, err := openDB()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer db.Close()
+
+	return storeToken(db, options, data), nil
 }
 
-// LookUpSession search token in the database
-// and return session struct
-func lookUpSession(db *bbolt.DB) (session *Session, err error) {
-	db.Update(func(tx *bbolt.Tx) error {
-		bucket := new(bbolt.Bucket)
-		bucket, err = tx.CreateBucketIfNotExists([]byte("session"))
+// LoginWithToken stores a personal access token in the selected profile
+// and made it active
+func LoginWithToken(options Options, privateToken string) (*Session, error) {
+	if privateToken == "" {
+		return nil, errors.New("token is required")
+	}
+
+	options, err := remoteProfile(options)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkProfileName(options.profile()); err != nil {
+		return nil, err
+	}
+
+	db, err := openDB()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer db.Close()
+
+	return storeToken(db, options, &token{
+		AccessToken: privateToken,
+		TokenType:   PrivateToken,
+	}), nil
+}
+
+// Profiles returns the stored profiles and the name of the active one
+func Profiles() ([]*Session, string, error) {
+	db, err := openDB()
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	defer db.Close()
+
+	var profiles []*Session
+	var active string
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		bucket, err := sessionBucket(tx)
 
 		if err != nil {
 			return err
 		}
 
-		token := bucket.Get([]byte("access_token"))
-		tokenType := bucket.Get([]byte("token_type"))
+		active = string(bucket.Get(activeKey))
+		profiles = readProfiles(bucket)
+
+		return nil
+	})
+
+	return profiles, active, err
+}
 
-		if len(token) == 0 || len(tokenType) == 0 {
-			err = errors.New("no tokens")
+// Switch set the active profile
+func Switch(name string) error {
+	if err := checkProfileName(name); err != nil {
+		return err
+	}
+
+	db, err := openDB()
+
+	if err != nil {
+		return err
+	}
+
+	defer db.Close()
+
+	return db.Update(func(tx *bbolt.Tx) error {
+		bucket, err := sessionBucket(tx)
+
+		if err != nil {
 			return err
 		}
 
-		session = &Session{
-			Token: string(token),
-			Type:  string(tokenType),
+		if readProfile(bucket, name) == nil {
+			return fmt.Errorf("profile %s does not exist", name)
 		}
 
-		return err
+		return bucket.Put(activeKey, []byte(name))
 	})
-
-	return
 }
 
-func storeToken(db *bbolt.DB, data map[string]string) *Session {
-	err := db.Update(func(tx *bbolt.Tx) error {
-		bucket, err := tx.CreateBucketIfNotExists([]byte("session"))
+// LookUpSession search the profile selected by the options
+// and return session struct
+func lookUpSession(db *bbolt.DB, options Options) (session *Session, err error) {
+	if err := checkProfileName(options.Profile); err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		bucket, err := sessionBucket(tx)
+
 		if err != nil {
-			color.Red.Println(err.Error())
-			os.Exit(1)
+			return err
 		}
 
-		err = bucket.Put([]byte("access_token"), []byte(data["access_token"]))
-		err = bucket.Put([]byte("token_type"), []byte(data["token_type"]))
+		active := readProfile(bucket, string(bucket.Get(activeKey)))
+
+		switch {
+		case options.Profile != "":
+			session = readProfile(bucket, options.Profile)
+		case options.Host != "":
+			session = hostProfile(bucket, active, options.Host)
+		case options.Remote != "":
+			if session = hostProfile(bucket, active, options.Remote); session == nil {
+				session = active
+			}
+		default:
+			session = active
+		}
 
-		return err
+		if session == nil {
+			return ErrNoSession
+		}
+
+		return decryptSession(bucket, session)
 	})
 
-	if err != nil {
-		color.Red.Println(err.Error())
-		os.Exit(1)
-	}
+	return
+}
 
-	color.Green.Light().Println("Login successful!")
-	println()
+// hostProfile returns the active profile if it belongs to the host,
+// or the first stored profile of the host
+func hostProfile(bucket *bbolt.Bucket, active *Session, host string) *Session {
+	if active != nil && sameHost(active.Host, host) {
+		return active
+	}
 
-	return &Session{
-		Token: data["access_token"],
-		Type:  data["token_type"],
+	for _, profile := range readProfiles(bucket) {
+		if sameHost(profile.Host, host) {
+			return profile
+		}
 	}
+
+	return nil
 }
 
-func login() map[string]string {
-	color.Cyan.Println("Logging with gitlab")
-	srv := &http.Server{Addr: "0.0.0.0:7890"}
+// remoteProfile select the stored profile of the remote host for a login
+// without host and profile, other remotes login in the default host
+func remoteProfile(options Options) (Options, error) {
+	if options.Host != "" || options.Profile != "" || options.Remote == "" {
+		return options, nil
+	}
+
+	db, err := openDB()
 
-	var data map[string]string
-	spinner := utils.ShowSpinner()
+	if err != nil {
+		return options, err
+	}
+
+	defer db.Close()
 
-	openBrowser()
+	err = db.Update(func(tx *bbolt.Tx) error {
+		bucket, err := sessionBucket(tx)
 
-	http.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
-		spinner.Stop()
-		err := json.NewDecoder(r.Body).Decode(&data)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
+			return err
 		}
 
-		srv.Shutdown(context.TODO())
-	})
+		if profile := hostProfile(bucket, nil, options.Remote); profile != nil {
+			options.Host = profile.Host
+			options.Profile = profile.Profile
+		}
 
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprintf(w, `
-		<html>
-			<head></head>
-			<body>
-				<script>
-					function getHashParams() {
-						var hashParams = {};
-						var e,
-							a = /\+/g,  // Regex for replacing addition symbol with a space
-							r = /([^&;=]+)=?([^&;]*)/g,
-							d = function (s) { return decodeURIComponent(s.replace(a, " ")); },
-							q = window.location.hash.substring(1);
-
-						while (e = r.exec(q))
-						hashParams[d(e[1])] = d(e[2]);
-
-						return hashParams;
-					}
-
-					var xhr = new XMLHttpRequest();
-					xhr.open('POST', 'http://localhost:7890/token', true);
-					xhr.setRequestHeader('Content-type', 'application/json');
-					xhr.send(JSON.stringify(getHashParams()));
-					
-					window.close();
-				</script>
-			</body>
-		</html>
-	`)
+		return nil
 	})
 
-	srv.ListenAndServe()
-	return data
+	return options, err
 }
 
-func openBrowser() error {
-	var cmd string
-	var args []string
+func storeToken(db *bbolt.DB, options Options, data *token) *Session {
+	session := &Session{
+		Profile:      options.profile(),
+		Token:        data.AccessToken,
+		Type:         data.TokenType,
+		Host:         options.host(),
+		RefreshToken: data.RefreshToken,
+		Expiry:       data.expiry(),
+		Scopes:       data.scopes(),
+		Redirect:     data.redirect,
+	}
+
+	err := db.Update(func(tx *bbolt.Tx) error {
+		bucket, err := sessionBucket(tx)
+
+		if err != nil {
+			return err
+		}
+
+		if err = writeProfile(bucket, session); err != nil {
+			return err
+		}
 
-	switch runtime.GOOS {
-	case "windows":
-		cmd = "cmd"
-		args = []string{"/c", "start"}
-	case "darwin":
-		cmd = "open"
-	default: // "linux", "freebsd", "openbsd", "netbsd"
-		cmd = "xdg-open"
+		return bucket.Put(activeKey, []byte(session.Profile))
+	})
+
+	if err != nil {
+		color.Red.Println(err.Error())
+		os.Exit(1)
 	}
-	args = append(args,
-		fmt.Sprintf("https://gitlab.com/oauth/authorize?client_id=%s&redirect_uri=%s&response_type=token&scope=api",
-			applicationid, callback),
-	)
 
-	return exec.Command(cmd, args...).Start()
-}
+	color.Green.Light().Println("Login successful!")
+	println()
 
-// fmt.Sprintf("https://gitlab.com/oauth/authorize?client_id=%s&redirect_uri=%s&response_type=token&state=%s&scope=api",
-// 	applicationid, callback, "asd"),
+	return session
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// ReadCredential parse the key=value lines of the git credential helper protocol
+// until an empty line or the end of the input
+func ReadCredential(r io.Reader) map[string]string {
+	credential := map[string]string{}
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if line == "" {
+			break
+		}
+
+		if parts := strings.SplitN(line, "=", 2); len(parts) == 2 {
+			credential[parts[0]] = parts[1]
+		}
+	}
+
+	return credential
+}
+
+// sameHost returns true if both hosts resolve to the same instance url
+func sameHost(a string, b string) bool {
+	return BaseURL(a) == BaseURL(b)
+}
+
+// credentialHost returns the session host of a git credential request
+func credentialHost(credential map[string]string) string {
+	if credential["protocol"] == "http" {
+		return "http://" + credential["host"]
+	}
+	return credential["host"]
+}
+
+// Credential returns the session for the host of a git credential request,
+// nil if there is no session for the host
+func Credential(credential map[string]string) (*Session, error) {
+	if protocol := credential["protocol"]; protocol != "https" && protocol != "http" {
+		return nil, nil
+	}
+
+	host := credentialHost(credential)
+
+	if session := sessionFromEnv(""); session != nil {
+		if sameHost(session.Host, host) {
+			return session, nil
+		}
+		return nil, nil
+	}
+
+	db, err := openDB()
+
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := lookUpSession(db, Options{Host: host})
+	db.Close()
+
+	if err == ErrNoSession {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if session.Expired() {
+		if err := Refresh(session); err != nil {
+			return nil, err
+		}
+	}
+
+	return session, nil
+}
+
+// WriteCredential write the session as answer to a git credential request
+func WriteCredential(w io.Writer, credential map[string]string, session *Session) {
+	username := "oauth2"
+
+	if session.Type == JobToken {
+		username = "gitlab-ci-token"
+	}
+
+	fmt.Fprintf(w, "protocol=%s\n", credential["protocol"])
+	fmt.Fprintf(w, "host=%s\n", credential["host"])
+	fmt.Fprintf(w, "username=%s\n", username)
+	fmt.Fprintf(w, "password=%s\n", session.Token)
+}
+
+// CredentialURL returns the url used to scope the git credential helper to a host
+func CredentialURL(host string) string {
+	if u, err := url.Parse(BaseURL(host)); err == nil {
+		return fmt.Sprintf("%s://%s", u.Scheme, u.Host)
+	}
+	return BaseURL(host)
+}
@@ -0,0 +1,210 @@
+package auth
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/angel-afonso/gitlabcli/utils"
+	"go.etcd.io/bbolt"
+)
+
+// storedProfiles set a temporary home with the given profiles in the session
+// database, the environment tokens are unset so the database is used
+func storedProfiles(t *testing.T, profiles ...*Session) {
+	dir, err := ioutil.TempDir("", "gitlabcli")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	home := os.Getenv("HOME")
+	os.Setenv("HOME", dir)
+
+	for _, name := range []string{"GITLAB_TOKEN", "CI_JOB_TOKEN"} {
+		name := name
+
+		if value, ok := os.LookupEnv(name); ok {
+			os.Unsetenv(name)
+			t.Cleanup(func() { os.Setenv(name, value) })
+		}
+	}
+
+	t.Cleanup(func() {
+		os.Setenv("HOME", home)
+		os.RemoveAll(dir)
+	})
+
+	db, err := openDB()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	db.Update(func(tx *bbolt.Tx) error {
+		bucket, _ := sessionBucket(tx)
+
+		for _, profile := range profiles {
+			writeProfile(bucket, profile)
+		}
+
+		return bucket.Put(activeKey, []byte(profiles[0].Profile))
+	})
+}
+
+func TestCredentialFromEnvironment(t *testing.T) {
+	os.Setenv("GITLAB_TOKEN", "secret")
+	os.Setenv("CI_SERVER_URL", "https://gitlab.example.org")
+	defer os.Unsetenv("GITLAB_TOKEN")
+	defer os.Unsetenv("CI_SERVER_URL")
+
+	credential := ReadCredential(strings.NewReader("protocol=https\nhost=gitlab.example.org\n\nignored=true\n"))
+	assert.Equal(t, map[string]string{"protocol": "https", "host": "gitlab.example.org"}, credential)
+
+	session, err := Credential(credential)
+	assert.NoError(t, err)
+
+	var output bytes.Buffer
+	WriteCredential(&output, credential, session)
+
+	assert.Equal(t, "protocol=https\nhost=gitlab.example.org\nusername=oauth2\npassword=secret\n", output.String())
+
+	session, err = Credential(map[string]string{"protocol": "https", "host": "github.com"})
+	assert.NoError(t, err)
+	assert.Nil(t, session)
+}
+
+func TestCredentialFromProfile(t *testing.T) {
+	storedProfiles(t,
+		&Session{Profile: "oss", Token: "oss-token", Type: "Bearer", Host: DefaultHost},
+		&Session{Profile: "work", Token: "work-token", Type: PrivateToken, Host: "git.corp.example.com"},
+	)
+
+	session, err := Credential(map[string]string{"protocol": "https", "host": "git.corp.example.com"})
+	assert.NoError(t, err)
+
+	var output bytes.Buffer
+	WriteCredential(&output, map[string]string{"protocol": "https", "host": "git.corp.example.com"}, session)
+	assert.Equal(t, "protocol=https\nhost=git.corp.example.com\nusername=oauth2\npassword=work-token\n", output.String())
+
+	session, err = Credential(map[string]string{"protocol": "https", "host": "gitlab.com"})
+	assert.NoError(t, err)
+	assert.Equal(t, "oss", session.Profile)
+
+	session, err = Credential(map[string]string{"protocol": "https", "host": "gitlab.unknown.org"})
+	assert.NoError(t, err)
+	assert.Nil(t, session)
+
+	session, err = Credential(map[string]string{"protocol": "ssh", "host": "git.corp.example.com"})
+	assert.NoError(t, err)
+	assert.Nil(t, session)
+}
+
+func TestCredentialRefresh(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		assert.Equal(t, "/oauth/token", r.URL.Path)
+		assert.Equal(t, "refresh_token", r.PostForm.Get("grant_type"))
+		assert.Equal(t, "refresh", r.PostForm.Get("refresh_token"))
+		assert.Equal(t, "http://127.0.0.1:41234", r.PostForm.Get("redirect_uri"))
+
+		w.Write([]byte(`{"access_token":"new-token","token_type":"Bearer","refresh_token":"new-refresh","expires_in":7200}`))
+	}))
+	defer server.Close()
+
+	storedProfiles(t, &Session{
+		Profile:      "local",
+		Token:        "expired-token",
+		Type:         "Bearer",
+		Host:         server.URL,
+		RefreshToken: "refresh",
+		Expiry:       time.Now().Add(-time.Minute),
+		Redirect:     "http://127.0.0.1:41234",
+	})
+
+	host, _ := url.Parse(server.URL)
+
+	session, err := Credential(map[string]string{"protocol": "http", "host": host.Host})
+	assert.NoError(t, err)
+	assert.Equal(t, "new-token", session.Token)
+	assert.False(t, session.Expired())
+
+	// the refreshed token is stored in the profile
+	stored, err := LookUp(Options{Profile: "local"})
+	assert.NoError(t, err)
+	assert.Equal(t, "new-token", stored.Token)
+	assert.Equal(t, "new-refresh", stored.RefreshToken)
+	assert.Equal(t, "http://127.0.0.1:41234", stored.Redirect)
+}
+
+func TestCredentialEncrypted(t *testing.T) {
+	storedProfiles(t, &Session{Profile: "work", Token: "work-token", Type: PrivateToken, Host: "git.corp.example.com"})
+
+	defer func(original func(string) (string, error)) {
+		readPassphrase = original
+		sealKey = nil
+		os.Unsetenv("GITLABCLI_PASSPHRASE")
+	}(readPassphrase)
+
+	os.Setenv("GITLABCLI_PASSPHRASE", "passphrase")
+	assert.NoError(t, Encrypt())
+	os.Unsetenv("GITLABCLI_PASSPHRASE")
+
+	// git writes the request to stdin and closes it
+	stdin := os.Stdin
+	defer func() { os.Stdin = stdin }()
+
+	reader, writer, err := os.Pipe()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Stdin = reader
+	writer.WriteString("protocol=https\nhost=git.corp.example.com\n\n")
+	writer.Close()
+
+	credential := ReadCredential(os.Stdin)
+
+	// without terminal the passphrase can not be asked
+	sealKey = nil
+	readPassphrase = func(string) (string, error) {
+		return "", utils.ErrNoTerminal
+	}
+
+	_, err = Credential(credential)
+	assert.Equal(t, errNoPassphrase, err)
+
+	// the terminal is read apart from stdin
+	sealKey = nil
+	readPassphrase = func(string) (string, error) {
+		return "passphrase", nil
+	}
+
+	session, err := Credential(credential)
+	assert.NoError(t, err)
+	assert.Equal(t, "work-token", session.Token)
+
+	sealKey = nil
+	readPassphrase = func(string) (string, error) {
+		t.Error("the passphrase is asked although GITLABCLI_PASSPHRASE is set")
+		return "", utils.ErrNoTerminal
+	}
+	os.Setenv("GITLABCLI_PASSPHRASE", "passphrase")
+
+	session, err = Credential(credential)
+	assert.NoError(t, err)
+
+	var output bytes.Buffer
+	WriteCredential(&output, credential, session)
+	assert.Equal(t, "protocol=https\nhost=git.corp.example.com\nusername=oauth2\npassword=work-token\n", output.String())
+}
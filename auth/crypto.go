@@ -0,0 +1,309 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"gitlab.com/angel-afonso/gitlabcli/utils"
+	"go.etcd.io/bbolt"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	encryptedPrefix = "enc:"
+	checkValue      = "gitlabcli"
+
+	passphraseMode = "passphrase"
+	keyFileMode    = "keyfile"
+)
+
+var (
+	encryptionKey = []byte("encryption")
+	saltKey       = []byte("salt")
+	checkKey      = []byte("check")
+
+	// sealKey is the key derived for this process, the passphrase is asked once
+	sealKey []byte
+
+	// readPassphrase asks the passphrase in the terminal, replaced in tests
+	readPassphrase = utils.ReadTerminalPassword
+)
+
+// ErrWrongKey is returned when the passphrase or key file can not decrypt the session
+var ErrWrongKey = errors.New("the passphrase or key file can not decrypt the session")
+
+// keyFromFile returns the key derived from the GITLABCLI_KEY_FILE contents
+func keyFromFile() ([]byte, error) {
+	file := os.Getenv("GITLABCLI_KEY_FILE")
+
+	if file == "" {
+		return nil, errors.New("the session is encrypted with a key file, set its path in GITLABCLI_KEY_FILE")
+	}
+
+	content, err := ioutil.ReadFile(file)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(content)
+	return sum[:], nil
+}
+
+// errNoPassphrase is returned when the passphrase is not set and can not be asked
+var errNoPassphrase = errors.New("the session is encrypted and there is no terminal to ask the passphrase, set it in GITLABCLI_PASSPHRASE, or set GITLABCLI_KEY_FILE for sessions encrypted with a key file")
+
+// askPassphrase asks the passphrase in the terminal, stdin can not be used
+// because it has the input of commands like auth git-credential
+func askPassphrase(prompt string) (string, error) {
+	passphrase, err := readPassphrase(prompt)
+
+	if errors.Is(err, utils.ErrNoTerminal) {
+		return "", errNoPassphrase
+	}
+
+	return passphrase, err
+}
+
+// keyFromPassphrase returns the key derived from GITLABCLI_PASSPHRASE,
+// the passphrase is asked if the variable is not set
+func keyFromPassphrase(salt []byte, confirm bool) ([]byte, error) {
+	passphrase := os.Getenv("GITLABCLI_PASSPHRASE")
+
+	if passphrase == "" {
+		var err error
+
+		if passphrase, err = askPassphrase("Session passphrase: "); err != nil {
+			return nil, err
+		}
+
+		if confirm {
+			repeated, err := askPassphrase("Repeat passphrase: ")
+
+			if err != nil {
+				return nil, err
+			}
+
+			if repeated != passphrase {
+				return nil, errors.New("passphrases do not match")
+			}
+		}
+	}
+
+	if passphrase == "" {
+		return nil, errors.New("passphrase is required")
+	}
+
+	return scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+}
+
+// encrypted returns true if the session bucket values are encrypted
+func encrypted(bucket *bbolt.Bucket) bool {
+	return bucket.Get(encryptionKey) != nil
+}
+
+// derivedKey returns the key of an encrypted session bucket
+func derivedKey(bucket *bbolt.Bucket) ([]byte, error) {
+	if sealKey != nil {
+		return sealKey, nil
+	}
+
+	var derived []byte
+	var err error
+
+	switch string(bucket.Get(encryptionKey)) {
+	case keyFileMode:
+		derived, err = keyFromFile()
+	default:
+		derived, err = keyFromPassphrase(bucket.Get(saltKey), false)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if check, err := open(derived, string(bucket.Get(checkKey))); err != nil || check != checkValue {
+		return nil, ErrWrongKey
+	}
+
+	sealKey = derived
+	return sealKey, nil
+}
+
+func seal(key []byte, value string) (string, error) {
+	block, err := aes.NewCipher(key)
+
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(value), nil)), nil
+}
+
+func open(key []byte, value string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+
+	if err != nil {
+		return "", err
+	}
+
+	block, err := aes.NewCipher(key)
+
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+
+	if err != nil {
+		return "", err
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return "", ErrWrongKey
+	}
+
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+
+	if err != nil {
+		return "", ErrWrongKey
+	}
+
+	return string(plain), nil
+}
+
+// encryptValue encrypts the value if the session bucket is encrypted
+func encryptValue(bucket *bbolt.Bucket, value string) (string, error) {
+	if !encrypted(bucket) || value == "" {
+		return value, nil
+	}
+
+	key, err := derivedKey(bucket)
+
+	if err != nil {
+		return "", err
+	}
+
+	return seal(key, value)
+}
+
+// decryptSession decrypts the tokens of a session read from the bucket
+func decryptSession(bucket *bbolt.Bucket, session *Session) error {
+	for _, value := range []*string{&session.Token, &session.RefreshToken} {
+		if !strings.HasPrefix(*value, encryptedPrefix) {
+			continue
+		}
+
+		key, err := derivedKey(bucket)
+
+		if err != nil {
+			return err
+		}
+
+		if *value, err = open(key, *value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Encrypt encrypts the tokens stored in the session database, the key is derived
+// from the file in GITLABCLI_KEY_FILE, or from a passphrase given in
+// GITLABCLI_PASSPHRASE or asked in the terminal
+func Encrypt() error {
+	db, err := openDB()
+
+	if err != nil {
+		return err
+	}
+
+	defer db.Close()
+
+	return encryptSession(db)
+}
+
+// encryptSession set up the encryption of the session bucket
+// and encrypts the tokens of the stored profiles
+func encryptSession(db *bbolt.DB) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		bucket, err := sessionBucket(tx)
+
+		if err != nil {
+			return err
+		}
+
+		if encrypted(bucket) {
+			return errors.New("the session is already encrypted")
+		}
+
+		mode := passphraseMode
+		salt := make([]byte, 16)
+
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+
+		var derived []byte
+
+		if os.Getenv("GITLABCLI_KEY_FILE") != "" {
+			mode = keyFileMode
+			derived, err = keyFromFile()
+		} else {
+			derived, err = keyFromPassphrase(salt, true)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		check, err := seal(derived, checkValue)
+
+		if err != nil {
+			return err
+		}
+
+		profiles := readProfiles(bucket)
+
+		values := map[string][]byte{
+			string(encryptionKey): []byte(mode),
+			string(saltKey):       salt,
+			string(checkKey):      []byte(check),
+		}
+
+		for name, value := range values {
+			if err := bucket.Put([]byte(name), value); err != nil {
+				return err
+			}
+		}
+
+		sealKey = derived
+
+		for _, profile := range profiles {
+			if err := writeProfile(bucket, profile); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/angel-afonso/gitlabcli/utils"
+	"go.etcd.io/bbolt"
+)
+
+func TestEncryptSession(t *testing.T) {
+	db := openTestDB(t)
+	defer func() { sealKey = nil }()
+
+	os.Setenv("GITLABCLI_PASSPHRASE", "passphrase")
+	defer os.Unsetenv("GITLABCLI_PASSPHRASE")
+
+	db.Update(func(tx *bbolt.Tx) error {
+		bucket, _ := sessionBucket(tx)
+		bucket.Put(activeKey, []byte("work"))
+		return writeProfile(bucket, &Session{Profile: "work", Token: "token", Type: "Bearer", Host: DefaultHost, RefreshToken: "refresh"})
+	})
+
+	assert.NoError(t, encryptSession(db))
+	assert.Error(t, encryptSession(db))
+
+	db.View(func(tx *bbolt.Tx) error {
+		stored := readProfile(tx.Bucket(sessionKey), "work")
+
+		assert.Contains(t, stored.Token, encryptedPrefix)
+		assert.Contains(t, stored.RefreshToken, encryptedPrefix)
+		return nil
+	})
+
+	sealKey = nil
+	session, err := lookUpSession(db, Options{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "token", session.Token)
+	assert.Equal(t, "refresh", session.RefreshToken)
+
+	sealKey = nil
+	os.Setenv("GITLABCLI_PASSPHRASE", "wrong")
+
+	_, err = lookUpSession(db, Options{})
+	assert.Equal(t, ErrWrongKey, err)
+}
+
+func TestPassphraseWithoutTerminal(t *testing.T) {
+	db := openTestDB(t)
+
+	defer func(original func(string) (string, error)) {
+		readPassphrase = original
+		sealKey = nil
+	}(readPassphrase)
+
+	os.Setenv("GITLABCLI_PASSPHRASE", "passphrase")
+
+	db.Update(func(tx *bbolt.Tx) error {
+		bucket, _ := sessionBucket(tx)
+		bucket.Put(activeKey, []byte("work"))
+		return writeProfile(bucket, &Session{Profile: "work", Token: "token", Type: "Bearer", Host: DefaultHost})
+	})
+
+	assert.NoError(t, encryptSession(db))
+
+	os.Unsetenv("GITLABCLI_PASSPHRASE")
+	sealKey = nil
+
+	readPassphrase = func(string) (string, error) {
+		return "", utils.ErrNoTerminal
+	}
+
+	_, err := lookUpSession(db, Options{})
+	assert.Equal(t, errNoPassphrase, err)
+
+	readPassphrase = func(prompt string) (string, error) {
+		assert.Equal(t, "Session passphrase: ", prompt)
+		return "passphrase", nil
+	}
+
+	session, err := lookUpSession(db, Options{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "token", session.Token)
+}
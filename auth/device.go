@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"gitlab.com/angel-afonso/gitlabcli/utils"
+
+	color "gopkg.in/gookit/color.v1"
+)
+
+const deviceGrant = "urn:ietf:params:oauth:grant-type:device_code"
+
+// sleep wait between token polls, replaced in tests
+var sleep = time.Sleep
+
+// deviceCode is the response of the device authorization endpoint
+type deviceCode struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+// requestDeviceCode start the device authorization
+func requestDeviceCode(host string) (*deviceCode, error) {
+	resp, err := HTTPClient.PostForm(BaseURL(host)+"/oauth/authorize_device", url.Values{
+		"client_id": {clientID()},
+		"scope":     {"api"},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		oauthErr := &oauthError{}
+
+		if err := json.NewDecoder(resp.Body).Decode(oauthErr); err != nil || oauthErr.Code == "" {
+			return nil, fmt.Errorf("device authorization failed with status %s", resp.Status)
+		}
+
+		return nil, oauthErr
+	}
+
+	code := &deviceCode{}
+
+	if err := json.NewDecoder(resp.Body).Decode(code); err != nil {
+		return nil, err
+	}
+
+	return code, nil
+}
+
+// deviceLogin authenticate with the oauth device authorization grant,
+// the user completes the login in a browser on any other device
+func deviceLogin(host string) (*token, error) {
+	code, err := requestDeviceCode(host)
+
+	if err != nil {
+		return nil, err
+	}
+
+	color.Cyan.Printf("Open %s and enter the code ", code.VerificationURI)
+	color.Bold.Println(code.UserCode)
+
+	if code.VerificationURIComplete != "" {
+		color.Gray.Printf("Or open %s\n", code.VerificationURIComplete)
+	}
+
+	spinner := utils.ShowSpinner()
+	defer spinner.Stop()
+
+	interval := time.Duration(code.Interval) * time.Second
+
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	deadline := time.Now().Add(time.Duration(code.ExpiresIn) * time.Second)
+
+	for {
+		sleep(interval)
+
+		data, err := requestToken(host, url.Values{
+			"client_id":   {clientID()},
+			"device_code": {code.DeviceCode},
+			"grant_type":  {deviceGrant},
+		})
+
+		if err == nil {
+			return data, nil
+		}
+
+		var oauthErr *oauthError
+
+		if !errors.As(err, &oauthErr) {
+			return nil, err
+		}
+
+		switch oauthErr.Code {
+		case "authorization_pending":
+		case "slow_down":
+			interval += 5 * time.Second
+		case "expired_token":
+			return nil, errors.New("the device code expired, run the login again")
+		case "access_denied":
+			return nil, errors.New("the authorization was denied")
+		default:
+			return nil, err
+		}
+
+		if code.ExpiresIn > 0 && time.Now().After(deadline) {
+			return nil, errors.New("the device code expired, run the login again")
+		}
+	}
+}
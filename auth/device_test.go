@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeviceLogin(t *testing.T) {
+	polls := []string{
+		`{"error":"authorization_pending"}`,
+		`{"error":"slow_down"}`,
+		`{"error":"authorization_pending"}`,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		switch r.URL.Path {
+		case "/oauth/authorize_device":
+			assert.Equal(t, "api", r.PostForm.Get("scope"))
+			w.Write([]byte(`{"device_code":"device","user_code":"ABCD-EFGH","verification_uri":"https://gitlab.example.org/oauth/device","expires_in":300,"interval":2}`))
+		case "/oauth/token":
+			assert.Equal(t, deviceGrant, r.PostForm.Get("grant_type"))
+			assert.Equal(t, "device", r.PostForm.Get("device_code"))
+
+			if len(polls) > 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(polls[0]))
+				polls = polls[1:]
+				return
+			}
+
+			w.Write([]byte(`{"access_token":"token","token_type":"Bearer","refresh_token":"refresh","expires_in":7200}`))
+		}
+	}))
+	defer server.Close()
+
+	var waits []time.Duration
+	sleep = func(d time.Duration) { waits = append(waits, d) }
+	defer func() { sleep = time.Sleep }()
+
+	data, err := deviceLogin(server.URL)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "token", data.AccessToken)
+	assert.Equal(t, "refresh", data.RefreshToken)
+	assert.Equal(t, []time.Duration{2 * time.Second, 2 * time.Second, 7 * time.Second, 7 * time.Second}, waits)
+}
+
+func TestDeviceLoginDenied(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/oauth/authorize_device":
+			w.Write([]byte(`{"device_code":"device","user_code":"ABCD","verification_uri":"https://gitlab.example.org/oauth/device","expires_in":300,"interval":1}`))
+		case "/oauth/token":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"access_denied"}`))
+		}
+	}))
+	defer server.Close()
+
+	sleep = func(time.Duration) {}
+	defer func() { sleep = time.Sleep }()
+
+	_, err := deviceLogin(server.URL)
+
+	assert.EqualError(t, err, "the authorization was denied")
+}
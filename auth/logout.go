@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"go.etcd.io/bbolt"
+)
+
+// LogoutResult is the result of removing a stored profile,
+// Revoked is nil when the token was revoked in the server
+type LogoutResult struct {
+	Session *Session
+	Revoked error
+}
+
+// revoke invalidates the session oauth token in the server
+func revoke(session *Session) error {
+	if session.Type == PrivateToken {
+		return errors.New("personal access tokens are not revoked, revoke it in the gitlab user settings")
+	}
+
+	resp, err := HTTPClient.PostForm(session.BaseURL()+"/oauth/revoke", url.Values{
+		"client_id": {clientID()},
+		"token":     {session.Token},
+	})
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("token revocation failed with status %s", resp.Status)
+	}
+
+	return nil
+}
+
+// Logout revokes the token of the selected profile, or of every profile if all is true,
+// and removes them from the session database. Credentials in the environment are ignored
+func Logout(options Options, all bool) ([]LogoutResult, error) {
+	db, err := openDB()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer db.Close()
+
+	return logout(db, options, all)
+}
+
+func logout(db *bbolt.DB, options Options, all bool) ([]LogoutResult, error) {
+	var sessions []*Session
+
+	if all {
+		err := db.Update(func(tx *bbolt.Tx) error {
+			bucket, err := sessionBucket(tx)
+
+			if err != nil {
+				return err
+			}
+
+			sessions = readProfiles(bucket)
+
+			for _, session := range sessions {
+				if err := decryptSession(bucket, session); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		session, err := lookUpSession(db, options)
+
+		if err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	if len(sessions) == 0 {
+		return nil, ErrNoSession
+	}
+
+	results := make([]LogoutResult, len(sessions))
+
+	for i, session := range sessions {
+		results[i] = LogoutResult{Session: session, Revoked: revoke(session)}
+	}
+
+	err := db.Update(func(tx *bbolt.Tx) error {
+		bucket, err := sessionBucket(tx)
+
+		if err != nil {
+			return err
+		}
+
+		active := string(bucket.Get(activeKey))
+
+		for _, session := range sessions {
+			if err := bucket.DeleteBucket([]byte(session.Profile)); err != nil {
+				return err
+			}
+
+			if session.Profile == active {
+				if err := bucket.Delete(activeKey); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+
+	return results, err
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.etcd.io/bbolt"
+)
+
+func TestLogout(t *testing.T) {
+	var revoked []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		assert.Equal(t, "/oauth/revoke", r.URL.Path)
+
+		if token := r.PostForm.Get("token"); token == "expired" {
+			w.WriteHeader(http.StatusForbidden)
+		} else {
+			revoked = append(revoked, token)
+		}
+	}))
+	defer server.Close()
+
+	db := openTestDB(t)
+
+	db.Update(func(tx *bbolt.Tx) error {
+		bucket, _ := sessionBucket(tx)
+		writeProfile(bucket, &Session{Profile: "oss", Token: "oss-token", Type: "Bearer", Host: server.URL})
+		writeProfile(bucket, &Session{Profile: "work", Token: "expired", Type: "Bearer", Host: server.URL})
+		writeProfile(bucket, &Session{Profile: "pat", Token: "pat-token", Type: PrivateToken, Host: server.URL})
+		return bucket.Put(activeKey, []byte("oss"))
+	})
+
+	results, err := logout(db, Options{}, false)
+
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, "oss", results[0].Session.Profile)
+	assert.NoError(t, results[0].Revoked)
+	assert.Equal(t, []string{"oss-token"}, revoked)
+
+	_, err = lookUpSession(db, Options{})
+	assert.Equal(t, ErrNoSession, err)
+
+	results, err = logout(db, Options{}, true)
+
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+
+	for _, result := range results {
+		assert.Error(t, result.Revoked, result.Session.Profile)
+	}
+
+	db.View(func(tx *bbolt.Tx) error {
+		assert.Empty(t, readProfiles(tx.Bucket(sessionKey)))
+		return nil
+	})
+
+	_, err = logout(db, Options{}, true)
+	assert.Equal(t, ErrNoSession, err)
+}
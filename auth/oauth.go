@@ -0,0 +1,355 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"gitlab.com/angel-afonso/gitlabcli/utils"
+
+	color "gopkg.in/gookit/color.v1"
+)
+
+var (
+	// HTTPClient is used for the requests to the oauth endpoints
+	HTTPClient = http.DefaultClient
+
+	// browse opens the authorization url, replaced in tests
+	browse = openBrowser
+
+	// loginTimeout is the time to wait for the browser callback
+	loginTimeout = 5 * time.Minute
+)
+
+// token is the response of the oauth token endpoint
+type token struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	CreatedAt    int64  `json:"created_at"`
+	Scope        string `json:"scope"`
+
+	// redirect is the redirect uri used to request the authorization code
+	redirect string
+}
+
+// expiry returns the expiration time of the access token,
+// zero if the token does not expire
+func (t *token) expiry() time.Time {
+	if t.ExpiresIn == 0 {
+		return time.Time{}
+	}
+
+	created := time.Now()
+
+	if t.CreatedAt != 0 {
+		created = time.Unix(t.CreatedAt, 0)
+	}
+
+	return created.Add(time.Duration(t.ExpiresIn) * time.Second)
+}
+
+// scopes returns the granted scopes, nil if the response does not include them
+func (t *token) scopes() []string {
+	return parseScopes(t.Scope)
+}
+
+// oauthError is the error response of the oauth endpoints
+type oauthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *oauthError) Error() string {
+	if e.Description != "" {
+		return e.Description
+	}
+	return e.Code
+}
+
+// TokenInfo describe an oauth access token
+type TokenInfo struct {
+	Scopes    []string `json:"scope"`
+	ExpiresIn int64    `json:"expires_in"`
+}
+
+// Inspect request the scopes and expiration of the session oauth token
+func Inspect(session *Session) (*TokenInfo, error) {
+	return InspectContext(context.Background(), session)
+}
+
+// InspectContext request the scopes and expiration of the session oauth token,
+// canceled with the given context
+func InspectContext(ctx context.Context, session *Session) (*TokenInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", session.BaseURL()+"/oauth/token/info", nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+session.Token)
+	resp, err := HTTPClient.Do(req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token info request failed with status %s", resp.Status)
+	}
+
+	info := &TokenInfo{}
+
+	if err := json.NewDecoder(resp.Body).Decode(info); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// randomString returns a url safe random string
+func randomString() string {
+	buffer := make([]byte, 32)
+	rand.Read(buffer)
+	return base64.RawURLEncoding.EncodeToString(buffer)
+}
+
+// challenge returns the S256 pkce challenge of the given verifier
+func challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// authorizeURL returns the url of the authorization code request
+func authorizeURL(host string, redirect string, state string, verifier string) string {
+	params := url.Values{
+		"client_id":             {clientID()},
+		"redirect_uri":          {redirect},
+		"response_type":         {"code"},
+		"state":                 {state},
+		"scope":                 {"api"},
+		"code_challenge":        {challenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	return fmt.Sprintf("%s/oauth/authorize?%s", BaseURL(host), params.Encode())
+}
+
+// requestToken post the given form to the oauth token endpoint
+func requestToken(host string, form url.Values) (*token, error) {
+	resp, err := HTTPClient.PostForm(BaseURL(host)+"/oauth/token", form)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		oauthErr := &oauthError{}
+
+		if err := json.NewDecoder(resp.Body).Decode(oauthErr); err != nil || oauthErr.Code == "" {
+			return nil, fmt.Errorf("token request failed with status %s", resp.Status)
+		}
+
+		return nil, oauthErr
+	}
+
+	data := &token{}
+
+	if err := json.NewDecoder(resp.Body).Decode(data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// callbackResult is the authorization response received in the callback server
+type callbackResult struct {
+	code string
+	err  error
+}
+
+// listenCallback listen on the registered callback port of the loopback interface,
+// or in a free port when it is taken
+func listenCallback() (net.Listener, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", callbackPort))
+
+	if err != nil {
+		return net.Listen("tcp", "127.0.0.1:0")
+	}
+
+	return listener, nil
+}
+
+// callbackHandler returns the handler of the oauth redirect,
+// the result is sent once for the request with the expected state
+func callbackHandler(state string, result chan<- callbackResult) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+
+		query := r.URL.Query()
+
+		if query.Get("state") != state {
+			http.Error(w, "invalid state", http.StatusBadRequest)
+			return
+		}
+
+		res := callbackResult{code: query.Get("code")}
+
+		if oauthErr := query.Get("error"); oauthErr != "" {
+			res.err = &oauthError{Code: oauthErr, Description: query.Get("error_description")}
+		} else if res.code == "" {
+			res.err = errors.New("authorization code not received")
+		}
+
+		fmt.Fprint(w, `<html><body>You can close this window and return to the terminal.</body></html>`)
+
+		select {
+		case result <- res:
+		default:
+		}
+	})
+
+	return mux
+}
+
+// login authenticate with the authorization code flow with pkce,
+// the code is received in the local callback server
+func login(host string) (*token, error) {
+	color.Cyan.Printf("Logging with %s\n", host)
+
+	listener, err := listenCallback()
+
+	if err != nil {
+		return nil, err
+	}
+
+	redirect := callback
+
+	if port := listener.Addr().(*net.TCPAddr).Port; port != callbackPort {
+		// gitlab matches the loopback ip literal with any port, unlike localhost,
+		// so the free port is accepted by applications with http://127.0.0.1:7890
+		redirect = fmt.Sprintf("http://127.0.0.1:%d", port)
+		color.Yellow.Printf("The port %d is taken, the redirect %s needs http://127.0.0.1:%d in the redirect URIs of the application, otherwise free the port or login with --device\n", callbackPort, redirect, callbackPort)
+	}
+
+	state := randomString()
+	verifier := randomString()
+	result := make(chan callbackResult, 1)
+
+	srv := &http.Server{Handler: callbackHandler(state, result)}
+	go srv.Serve(listener)
+	defer srv.Close()
+
+	authorize := authorizeURL(host, redirect, state, verifier)
+
+	if err := browse(authorize); err != nil {
+		color.Yellow.Printf("Could not open the browser, open %s or login with --device\n", authorize)
+	}
+
+	spinner := utils.ShowSpinner()
+
+	var res callbackResult
+
+	select {
+	case res = <-result:
+		spinner.Stop()
+	case <-time.After(loginTimeout):
+		spinner.Stop()
+		return nil, fmt.Errorf("login timed out after %s without response from the browser, try again or login with --device", loginTimeout)
+	}
+
+	if res.err != nil {
+		return nil, res.err
+	}
+
+	data, err := requestToken(host, url.Values{
+		"client_id":     {clientID()},
+		"code":          {res.code},
+		"grant_type":    {"authorization_code"},
+		"redirect_uri":  {redirect},
+		"code_verifier": {verifier},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	data.redirect = redirect
+	return data, nil
+}
+
+// Refresh request a new access token with the session refresh token
+// and store it in the session profile
+func Refresh(session *Session) error {
+	if session.RefreshToken == "" {
+		return errors.New("the session can not be refreshed, login again with gitlabcli auth login")
+	}
+
+	// the redirect must be the one of the login, profiles stored
+	// before it was saved used the registered callback
+	redirect := session.Redirect
+
+	if redirect == "" {
+		redirect = callback
+	}
+
+	data, err := requestToken(session.Host, url.Values{
+		"client_id":     {clientID()},
+		"refresh_token": {session.RefreshToken},
+		"grant_type":    {"refresh_token"},
+		"redirect_uri":  {redirect},
+	})
+
+	if err != nil {
+		return err
+	}
+
+	session.Token = data.AccessToken
+	session.Type = data.TokenType
+	session.RefreshToken = data.RefreshToken
+	session.Expiry = data.expiry()
+	session.Scopes = data.scopes()
+
+	return saveProfile(session)
+}
+
+func openBrowser(url string) error {
+	var cmd string
+	var args []string
+
+	switch runtime.GOOS {
+	case "windows":
+		cmd = "cmd"
+		args = []string{"/c", "start"}
+		// cmd start treats & as a command separator
+		url = strings.ReplaceAll(url, "&", "^&")
+	case "darwin":
+		cmd = "open"
+	default: // "linux", "freebsd", "openbsd", "netbsd"
+		cmd = "xdg-open"
+	}
+	args = append(args, url)
+
+	return exec.Command(cmd, args...).Start()
+}
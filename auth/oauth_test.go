@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeOAuthServer exchanges the code "code" checking the pkce verifier
+// against the challenge sent in the authorization url, the redirect uri
+// of the exchange is sent to the exchanged channel
+func fakeOAuthServer(t *testing.T, expected *string, exchanged chan<- string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		assert.Equal(t, "/oauth/token", r.URL.Path)
+		assert.Equal(t, "authorization_code", r.PostForm.Get("grant_type"))
+		assert.Equal(t, "code", r.PostForm.Get("code"))
+		assert.Equal(t, *expected, challenge(r.PostForm.Get("code_verifier")))
+		exchanged <- r.PostForm.Get("redirect_uri")
+
+		w.Write([]byte(`{"access_token":"token","token_type":"Bearer","refresh_token":"refresh","expires_in":7200}`))
+	}))
+}
+
+// fakeBrowser follows the authorization url to the callback server,
+// the query is sent with the received state, or the fake one if given
+func fakeBrowser(t *testing.T, sentChallenge *string, redirects chan<- string, queries ...url.Values) func(string) error {
+	return func(authorize string) error {
+		location, err := url.Parse(authorize)
+		assert.NoError(t, err)
+
+		params := location.Query()
+		*sentChallenge = params.Get("code_challenge")
+		redirects <- params.Get("redirect_uri")
+
+		go func() {
+			for _, query := range queries {
+				if query.Get("state") == "" {
+					query.Set("state", params.Get("state"))
+				}
+				resp, err := http.Get(params.Get("redirect_uri") + "/?" + query.Encode())
+				if assert.NoError(t, err) {
+					resp.Body.Close()
+				}
+			}
+		}()
+
+		return nil
+	}
+}
+
+func TestLogin(t *testing.T) {
+	if listener, err := net.Listen("tcp", "127.0.0.1:7890"); err != nil {
+		t.Skip("the port 7890 is taken")
+	} else {
+		listener.Close()
+	}
+
+	var challenge string
+	redirects := make(chan string, 1)
+	exchanged := make(chan string, 1)
+
+	server := fakeOAuthServer(t, &challenge, exchanged)
+	defer server.Close()
+
+	browse = fakeBrowser(t, &challenge, redirects,
+		url.Values{"code": {"forged"}, "state": {"forged"}},
+		url.Values{"code": {"code"}},
+	)
+	defer func() { browse = openBrowser }()
+
+	data, err := login(server.URL)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "token", data.AccessToken)
+	assert.Equal(t, "refresh", data.RefreshToken)
+
+	// the registered redirect of the application is used while its port is free
+	assert.Equal(t, "http://localhost:7890", <-redirects)
+	assert.Equal(t, "http://localhost:7890", <-exchanged)
+	assert.Equal(t, "http://localhost:7890", data.redirect)
+}
+
+func TestLoginPortTaken(t *testing.T) {
+	if listener, err := net.Listen("tcp", "127.0.0.1:7890"); err == nil {
+		defer listener.Close()
+	}
+
+	var challenge string
+	redirects := make(chan string, 1)
+	exchanged := make(chan string, 1)
+
+	server := fakeOAuthServer(t, &challenge, exchanged)
+	defer server.Close()
+
+	browse = fakeBrowser(t, &challenge, redirects, url.Values{"code": {"code"}})
+	defer func() { browse = openBrowser }()
+
+	data, err := login(server.URL)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "token", data.AccessToken)
+
+	// the fake browser followed the redirect to the fallback port,
+	// and the same redirect was used in the code exchange
+	redirect, err := url.Parse(<-redirects)
+	assert.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", redirect.Hostname())
+	assert.NotEqual(t, "7890", redirect.Port())
+	assert.Equal(t, redirect.String(), <-exchanged)
+
+	// the redirect is stored with the session for the refresh requests
+	assert.Equal(t, redirect.String(), data.redirect)
+}
+
+func TestLoginDenied(t *testing.T) {
+	var challenge string
+	redirects := make(chan string, 1)
+
+	browse = fakeBrowser(t, &challenge, redirects, url.Values{"error": {"access_denied"}, "error_description": {"The user denied the request"}})
+	defer func() { browse = openBrowser }()
+
+	_, err := login("gitlab.example.org")
+
+	assert.EqualError(t, err, "The user denied the request")
+}
+
+func TestLoginTimeout(t *testing.T) {
+	browse = func(string) error { return nil }
+	loginTimeout = 50 * time.Millisecond
+
+	defer func() {
+		browse = openBrowser
+		loginTimeout = 5 * time.Minute
+	}()
+
+	_, err := login("gitlab.example.org")
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "timed out")
+}
+
+func TestCallbackRejectsOtherRequests(t *testing.T) {
+	result := make(chan callbackResult, 1)
+	handler := callbackHandler("state", result)
+
+	for _, target := range []string{"/?code=code&state=forged", "/?code=code", "/favicon.ico?code=code&state=state"} {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", target, nil))
+
+		assert.NotEqual(t, http.StatusOK, recorder.Code, target)
+	}
+
+	assert.Empty(t, result)
+}
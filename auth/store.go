@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+var (
+	sessionKey = []byte("session")
+	activeKey  = []byte("active")
+
+	hostKey     = []byte("host")
+	tokenKey    = []byte("access_token")
+	typeKey     = []byte("token_type")
+	refreshKey  = []byte("refresh_token")
+	expiryKey   = []byte("expires_at")
+	scopesKey   = []byte("scopes")
+	redirectKey = []byte("redirect_uri")
+)
+
+// reservedNames are the keys of the session bucket, they can not name a profile
+var reservedNames = [][]byte{activeKey, encryptionKey, saltKey, checkKey, hostKey, tokenKey, typeKey}
+
+// checkProfileName returns an error if the name is a key of the session bucket
+func checkProfileName(name string) error {
+	for _, reserved := range reservedNames {
+		if name == string(reserved) {
+			return fmt.Errorf("%q is reserved and can not be used as profile name, choose another one with --profile", name)
+		}
+	}
+
+	return nil
+}
+
+// sessionBucket returns the bucket holding the profiles, each profile
+// is a nested bucket with the host, token and token type of the session
+func sessionBucket(tx *bbolt.Tx) (*bbolt.Bucket, error) {
+	bucket, err := tx.CreateBucketIfNotExists(sessionKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return bucket, migrate(bucket)
+}
+
+// migrate move the single session stored by previous versions
+// to a profile named after its host
+func migrate(bucket *bbolt.Bucket) error {
+	token := bucket.Get(tokenKey)
+
+	if token == nil {
+		return nil
+	}
+
+	session := &Session{
+		Token: string(token),
+		Type:  string(bucket.Get(typeKey)),
+		Host:  string(bucket.Get(hostKey)),
+	}
+
+	if session.Host == "" {
+		session.Host = DefaultHost
+	}
+
+	session.Profile = session.Host
+
+	for _, key := range [][]byte{tokenKey, typeKey, hostKey} {
+		if err := bucket.Delete(key); err != nil {
+			return err
+		}
+	}
+
+	if err := writeProfile(bucket, session); err != nil {
+		return err
+	}
+
+	return bucket.Put(activeKey, []byte(session.Profile))
+}
+
+// readProfile returns the session stored in the named profile,
+// or nil if the profile does not exist. Tokens of encrypted sessions
+// are not decrypted
+func readProfile(bucket *bbolt.Bucket, name string) *Session {
+	if name == "" {
+		return nil
+	}
+
+	profile := bucket.Bucket([]byte(name))
+
+	if profile == nil {
+		return nil
+	}
+
+	token := profile.Get(tokenKey)
+	tokenType := profile.Get(typeKey)
+
+	if len(token) == 0 || len(tokenType) == 0 {
+		return nil
+	}
+
+	session := &Session{
+		Profile:      name,
+		Token:        string(token),
+		Type:         string(tokenType),
+		Host:         string(profile.Get(hostKey)),
+		RefreshToken: string(profile.Get(refreshKey)),
+		Scopes:       parseScopes(string(profile.Get(scopesKey))),
+		Redirect:     string(profile.Get(redirectKey)),
+	}
+
+	if expiry, err := strconv.ParseInt(string(profile.Get(expiryKey)), 10, 64); err == nil && expiry > 0 {
+		session.Expiry = time.Unix(expiry, 0)
+	}
+
+	return session
+}
+
+// parseScopes split a space separated scope list, nil if it is empty
+func parseScopes(value string) []string {
+	if scopes := strings.Fields(value); len(scopes) > 0 {
+		return scopes
+	}
+	return nil
+}
+
+// readProfiles returns the sessions of all the stored profiles
+func readProfiles(bucket *bbolt.Bucket) []*Session {
+	var profiles []*Session
+
+	bucket.ForEach(func(name []byte, value []byte) error {
+		if profile := readProfile(bucket, string(name)); value == nil && profile != nil {
+			profiles = append(profiles, profile)
+		}
+		return nil
+	})
+
+	return profiles
+}
+
+// writeProfile store the session in its profile bucket,
+// tokens are encrypted if the session bucket is encrypted
+func writeProfile(bucket *bbolt.Bucket, session *Session) error {
+	if err := checkProfileName(session.Profile); err != nil {
+		return err
+	}
+
+	profile, err := bucket.CreateBucketIfNotExists([]byte(session.Profile))
+
+	if err != nil {
+		return err
+	}
+
+	var expiry int64
+
+	if !session.Expiry.IsZero() {
+		expiry = session.Expiry.Unix()
+	}
+
+	token, err := encryptValue(bucket, session.Token)
+
+	if err != nil {
+		return err
+	}
+
+	refresh, err := encryptValue(bucket, session.RefreshToken)
+
+	if err != nil {
+		return err
+	}
+
+	values := map[string]string{
+		string(hostKey):     session.Host,
+		string(tokenKey):    token,
+		string(typeKey):     session.Type,
+		string(refreshKey):  refresh,
+		string(expiryKey):   strconv.FormatInt(expiry, 10),
+		string(scopesKey):   strings.Join(session.Scopes, " "),
+		string(redirectKey): session.Redirect,
+	}
+
+	for key, value := range values {
+		if err := profile.Put([]byte(key), []byte(value)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// saveProfile update the stored profile of the session,
+// sessions from the environment are not stored
+func saveProfile(session *Session) error {
+	if session.Profile == "" {
+		return nil
+	}
+
+	db, err := openDB()
+
+	if err != nil {
+		return err
+	}
+
+	defer db.Close()
+
+	return db.Update(func(tx *bbolt.Tx) error {
+		bucket, err := sessionBucket(tx)
+
+		if err != nil {
+			return err
+		}
+
+		return writeProfile(bucket, session)
+	})
+}
@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.etcd.io/bbolt"
+)
+
+func openTestDB(t *testing.T) *bbolt.DB {
+	dir, err := ioutil.TempDir("", "gitlabcli")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := bbolt.Open(path.Join(dir, "session"), 0600, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		db.Close()
+		os.RemoveAll(dir)
+	})
+	return db
+}
+
+func TestMigrateLegacySession(t *testing.T) {
+	db := openTestDB(t)
+
+	db.Update(func(tx *bbolt.Tx) error {
+		bucket, _ := tx.CreateBucket(sessionKey)
+		bucket.Put(tokenKey, []byte("token"))
+		bucket.Put(typeKey, []byte("bearer"))
+		return nil
+	})
+
+	session, err := lookUpSession(db, Options{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, &Session{Profile: DefaultHost, Token: "token", Type: "bearer", Host: DefaultHost}, session)
+}
+
+func TestLookUpSessionProfiles(t *testing.T) {
+	db := openTestDB(t)
+
+	db.Update(func(tx *bbolt.Tx) error {
+		bucket, _ := sessionBucket(tx)
+		writeProfile(bucket, &Session{Profile: "oss", Token: "a", Type: "bearer", Host: DefaultHost})
+		writeProfile(bucket, &Session{Profile: "work", Token: "b", Type: PrivateToken, Host: "gitlab.example.org"})
+		return bucket.Put(activeKey, []byte("oss"))
+	})
+
+	cases := []struct {
+		options Options
+		profile string
+	}{
+		{Options{}, "oss"},
+		{Options{Profile: "work"}, "work"},
+		{Options{Host: "gitlab.example.org"}, "work"},
+		{Options{Host: DefaultHost}, "oss"},
+		{Options{Remote: "gitlab.example.org"}, "work"},
+		{Options{Remote: "codeberg.org"}, "oss"},
+		{Options{Remote: "work"}, "oss"},
+		{Options{Host: DefaultHost, Remote: "gitlab.example.org"}, "oss"},
+	}
+
+	for _, c := range cases {
+		session, err := lookUpSession(db, c.options)
+
+		assert.NoError(t, err)
+		assert.Equal(t, c.profile, session.Profile)
+	}
+
+	_, err := lookUpSession(db, Options{Host: "gitlab.unknown.org"})
+	assert.Error(t, err)
+
+	_, err = lookUpSession(db, Options{Profile: "missing"})
+	assert.Error(t, err)
+}
+
+func TestRemoteProfile(t *testing.T) {
+	storedProfiles(t,
+		&Session{Profile: "oss", Token: "a", Type: "bearer", Host: DefaultHost},
+		&Session{Profile: "work", Token: "b", Type: PrivateToken, Host: "git.corp.example.com"},
+	)
+
+	cases := []struct {
+		options  Options
+		expected Options
+	}{
+		{Options{Remote: "git.corp.example.com"}, Options{Host: "git.corp.example.com", Profile: "work", Remote: "git.corp.example.com"}},
+		{Options{Remote: "codeberg.org"}, Options{Remote: "codeberg.org"}},
+		{Options{Host: "gitlab.example.org", Remote: "git.corp.example.com"}, Options{Host: "gitlab.example.org", Remote: "git.corp.example.com"}},
+	}
+
+	for _, c := range cases {
+		options, err := remoteProfile(c.options)
+
+		assert.NoError(t, err)
+		assert.Equal(t, c.expected, options)
+	}
+
+	assert.Equal(t, DefaultHost, Options{Remote: "codeberg.org"}.host())
+}
+
+func TestReservedProfileNames(t *testing.T) {
+	db := openTestDB(t)
+
+	for _, name := range []string{"active", "salt", "encryption", "check"} {
+		_, err := lookUpSession(db, Options{Profile: name})
+		assert.EqualError(t, err, `"`+name+`" is reserved and can not be used as profile name, choose another one with --profile`)
+
+		err = db.Update(func(tx *bbolt.Tx) error {
+			bucket, _ := sessionBucket(tx)
+			return writeProfile(bucket, &Session{Profile: name, Token: "a", Type: "bearer", Host: DefaultHost})
+		})
+		assert.Error(t, err)
+		assert.NotEqual(t, bbolt.ErrIncompatibleValue, err)
+	}
+}
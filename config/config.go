@@ -0,0 +1,92 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+)
+
+// KeyPair is a client certificate and its private key, in pem files
+type KeyPair struct {
+	Cert string `json:"cert"`
+	Key  string `json:"key"`
+}
+
+// Config has the settings of the connection with gitlab
+type Config struct {
+	// Proxy url used for every request, the HTTPS_PROXY and HTTP_PROXY
+	// environment variables are used when it is empty
+	Proxy string `json:"proxy"`
+	// CAFiles are pem files with certificates trusted besides the system ones
+	CAFiles []string `json:"ca_files"`
+	// ClientCertificates are presented to the instances that require mutual tls
+	ClientCertificates []KeyPair `json:"client_certificates"`
+	// InsecureSkipVerify disables the verification of server certificates,
+	// only for test instances
+	InsecureSkipVerify bool `json:"insecure_skip_verify"`
+}
+
+// Path returns the location of the config file
+func Path() string {
+	if file := os.Getenv("GITLABCLI_CONFIG"); file != "" {
+		return file
+	}
+
+	homeDir, _ := os.UserHomeDir()
+	return path.Join(homeDir, ".gitlabcli", "config.json")
+}
+
+// Load read the config file, if it exists, and apply the environment variables over it
+func Load() (Config, error) {
+	var config Config
+
+	data, err := ioutil.ReadFile(Path())
+
+	if err == nil {
+		if err := json.Unmarshal(data, &config); err != nil {
+			return config, fmt.Errorf("invalid config file %s: %s", Path(), err.Error())
+		}
+	} else if !os.IsNotExist(err) {
+		return config, err
+	}
+
+	return config, config.fromEnv()
+}
+
+// fromEnv apply the GITLABCLI_PROXY, GITLABCLI_CA_FILE, GITLABCLI_CLIENT_CERT,
+// GITLABCLI_CLIENT_KEY and GITLABCLI_INSECURE_SKIP_VERIFY environment variables
+func (c *Config) fromEnv() error {
+	if proxy := os.Getenv("GITLABCLI_PROXY"); proxy != "" {
+		c.Proxy = proxy
+	}
+
+	if files := os.Getenv("GITLABCLI_CA_FILE"); files != "" {
+		c.CAFiles = append(c.CAFiles, filepath.SplitList(files)...)
+	}
+
+	cert, key := os.Getenv("GITLABCLI_CLIENT_CERT"), os.Getenv("GITLABCLI_CLIENT_KEY")
+
+	if cert != "" || key != "" {
+		if cert == "" || key == "" {
+			return fmt.Errorf("GITLABCLI_CLIENT_CERT and GITLABCLI_CLIENT_KEY must be set together")
+		}
+
+		c.ClientCertificates = append(c.ClientCertificates, KeyPair{Cert: cert, Key: key})
+	}
+
+	if insecure := os.Getenv("GITLABCLI_INSECURE_SKIP_VERIFY"); insecure != "" {
+		skip, err := strconv.ParseBool(insecure)
+
+		if err != nil {
+			return fmt.Errorf("invalid GITLABCLI_INSECURE_SKIP_VERIFY: %s", insecure)
+		}
+
+		c.InsecureSkipVerify = skip
+	}
+
+	return nil
+}
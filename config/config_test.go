@@ -0,0 +1,43 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitlabcli")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	file := path.Join(dir, "config.json")
+	ioutil.WriteFile(file, []byte(`{"proxy":"http://proxy:3128","ca_files":["/etc/ca.pem"]}`), 0600)
+
+	os.Setenv("GITLABCLI_CONFIG", file)
+	os.Setenv("GITLABCLI_CA_FILE", "/tmp/extra.pem")
+	os.Setenv("GITLABCLI_INSECURE_SKIP_VERIFY", "true")
+	defer os.Unsetenv("GITLABCLI_CONFIG")
+	defer os.Unsetenv("GITLABCLI_CA_FILE")
+	defer os.Unsetenv("GITLABCLI_INSECURE_SKIP_VERIFY")
+
+	config, err := Load()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "http://proxy:3128", config.Proxy)
+	assert.Equal(t, []string{"/etc/ca.pem", "/tmp/extra.pem"}, config.CAFiles)
+	assert.True(t, config.InsecureSkipVerify)
+
+	os.Setenv("GITLABCLI_CLIENT_CERT", "/tmp/client.pem")
+	defer os.Unsetenv("GITLABCLI_CLIENT_CERT")
+
+	_, err = Load()
+	assert.Error(t, err)
+}
@@ -3,6 +3,7 @@ module gitlab.com/angel-afonso/gitlabcli
 go 1.14
 
 require (
+	github.com/agnivade/levenshtein v1.1.1 // indirect
 	github.com/briandowns/spinner v1.11.1
 	github.com/cpuguy83/go-md2man/v2 v2.0.0 // indirect
 	github.com/eiannone/keyboard v0.0.0-20200508000154-caf4b762e807
@@ -10,7 +11,9 @@ require (
 	github.com/gookit/color v1.2.5
 	github.com/stretchr/testify v1.6.1
 	github.com/urfave/cli/v2 v2.2.0
+	github.com/vektah/gqlparser/v2 v2.2.0
 	go.etcd.io/bbolt v1.3.4
+	golang.org/x/crypto v0.0.0-20200302210943-78000ba7a073
 	golang.org/x/sys v0.0.0-20200323222414-85ca7c5b95cd // indirect
 	gopkg.in/gookit/color.v1 v1.1.6
 )
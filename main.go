@@ -1,47 +1,178 @@
 package main
 
 import (
-	"fmt"
+	"context"
 	"os"
-	"path"
+	"os/signal"
+	"syscall"
+	"time"
 
-	"github.com/gookit/color"
 	cli "github.com/urfave/cli/v2"
 	"gitlab.com/angel-afonso/gitlabcli/actions"
 	"gitlab.com/angel-afonso/gitlabcli/api"
-	"gitlab.com/angel-afonso/gitlabcli/auth"
 )
 
+// interruptible returns a context canceled on ctrl-c, so the requests in flight
+// are aborted and the commands clean up the terminal before returning.
+// The process exits if the command does not return in time, like while reading stdin
+func interruptible() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-signals
+		cancel()
+
+		select {
+		case <-signals:
+		case <-time.After(2 * time.Second):
+		}
+
+		println()
+		os.Exit(130)
+	}()
+
+	return ctx
+}
+
 func main() {
-	client := api.NewClient(auth.OpenSession())
+	var client api.Client
 
-	fmt.Println()
+	// authenticate is set as Before of the commands that send requests to gitlab,
+	// the session is opened after flag parsing so help and completion never login.
+	// The commands that send requests without session set actions.Configure instead,
+	// so local commands work with a broken config file
+	authenticate := actions.Authenticate(&client)
+
+	// blank lines go to stderr, the stdout of some commands is read by git
+	println()
 
 	app := &cli.App{
 		Name:        "gitlabcli",
 		Usage:       "Gitlab CLI",
 		Version:     "0.2.0",
 		Description: "Command line interface to interact with the gitlab API",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "host",
+				Usage:   "Gitlab instance host, defaults to the session host or gitlab.com",
+				EnvVars: []string{"GITLAB_HOST"},
+			},
+			&cli.StringFlag{
+				Name:  "profile",
+				Usage: "Stored profile used for this run, defaults to the active profile",
+			},
+			&cli.IntFlag{
+				Name:    "retries",
+				Usage:   "Number of times a failed read request is retried",
+				Value:   api.DefaultRetries,
+				EnvVars: []string{"GITLABCLI_RETRIES"},
+			},
+			&cli.DurationFlag{
+				Name:    "timeout",
+				Usage:   "Time limit of each request to gitlab, 0 disables it",
+				Value:   30 * time.Second,
+				EnvVars: []string{"GITLABCLI_TIMEOUT"},
+			},
+			&cli.BoolFlag{
+				// GITLABCLI_DEBUG is read by newClient, false boolean values disable it
+				Name:  "debug",
+				Usage: "Log the requests to gitlab in the standard error, also enabled by GITLABCLI_DEBUG unless it is 0 or false",
+			},
+			&cli.StringFlag{
+				Name:    "debug-file",
+				Usage:   "Write a trace of the requests in HAR format to the file, for bug reports",
+				EnvVars: []string{"GITLABCLI_DEBUG_FILE"},
+			},
+		},
 		Commands: []*cli.Command{
+			{
+				Name:        "auth",
+				Usage:       "Handle authentication",
+				Description: "Authentication related commands",
+				Subcommands: []*cli.Command{
+					{
+						Name:        "login",
+						Usage:       "Login to gitlab",
+						Description: "Login with the browser, with a code on another device if --device is given, or with a personal access token if --token is given. The session is stored in the profile given by --profile, or in a profile named after the host",
+						UsageText:   "gitlabcli [--host <host>] [--profile <name>] auth login [--device | --token <token>]",
+						Before:      actions.Configure,
+						Action:      actions.Login,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:        "token",
+								Usage:       "Personal access token, use - to read it from stdin",
+								Aliases:     []string{"t"},
+								Destination: &actions.Token,
+							},
+							&cli.BoolFlag{
+								Name:        "device",
+								Usage:       "Login with a code in the browser of another device, for machines without browser",
+								Destination: &actions.Device,
+							},
+						},
+					},
+					{
+						Name:        "status",
+						Usage:       "Display authentication status",
+						Description: "Display the user, host, scopes and expiration of the session token. Exits with error if the token is not valid",
+						UsageText:   "gitlabcli auth status",
+						Before:      actions.Configure,
+						Action:      actions.AuthStatus,
+					},
+					{
+						Name:        "list",
+						Usage:       "List profiles",
+						Description: "Display the stored profiles, the active profile is marked with *",
+						UsageText:   "gitlabcli auth list",
+						Action:      actions.ProfileList,
+					},
+					{
+						Name:        "switch",
+						Usage:       "Switch active profile",
+						Description: "Set the profile used when --profile is not given",
+						UsageText:   "gitlabcli auth switch <name>",
+						Action:      actions.SwitchProfile,
+					},
+					{
+						Name:        "git-credential",
+						Usage:       "Git credential helper",
+						Description: "Implements the git credential helper protocol, answers get requests with the session of the requested host",
+						UsageText:   "gitlabcli auth git-credential <get|store|erase>",
+						Hidden:      true,
+						Before:      actions.Configure,
+						Action:      actions.GitCredential,
+					},
+					{
+						Name:        "setup-git",
+						Usage:       "Configure git to use gitlabcli as credential helper",
+						Description: "Register gitlabcli as git credential helper for the --host, or for the hosts of every stored profile",
+						UsageText:   "gitlabcli [--host <host>] auth setup-git",
+						Action:      actions.SetupGit,
+					},
+					{
+						Name:        "encrypt",
+						Usage:       "Encrypt stored tokens",
+						Description: "Encrypt the stored tokens with a key derived from the file in GITLABCLI_KEY_FILE, or from a passphrase given in GITLABCLI_PASSPHRASE or asked in the terminal. Following logins are stored encrypted",
+						UsageText:   "gitlabcli auth encrypt",
+						Action:      actions.EncryptSession,
+					},
+				},
+			},
 			{
 				Name:        "logout",
-				Description: "Remove current session",
+				Description: "Revoke the token and remove the session of the selected profile, or of every profile with --all",
 				Usage:       "Remove session",
-				UsageText:   "gitlabcli logout",
-				Action: func(context *cli.Context) error {
-					homeDir, _ := os.UserHomeDir()
-					sessionDir := path.Join(homeDir, ".gitlabcli", "session")
-
-					if _, err := os.Stat(sessionDir); os.IsNotExist(err) {
-						color.Red.Printf("Session does not exist\n")
-						return nil
-					}
-
-					if err := os.Remove(sessionDir); err != nil {
-						return err
-					}
-					color.Success.Println("Logged out")
-					return nil
+				UsageText:   "gitlabcli logout [--all]",
+				Before:      actions.Configure,
+				Action:      actions.Logout,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:        "all",
+						Usage:       "Remove every stored profile",
+						Destination: &actions.LogoutAll,
+					},
 				},
 			},
 			{
@@ -51,21 +182,38 @@ func main() {
 				Subcommands: []*cli.Command{
 					{
 						Name:        "list",
-						UsageText:   "gitlabcli project list",
+						UsageText:   "gitlabcli project list [--limit <n> | --all]",
 						Usage:       "List projects",
 						Description: "Display a list with user's project",
+						Before:      authenticate,
 						Action:      actions.ProjectList(&client),
+						Flags: []cli.Flag{
+							&cli.IntFlag{
+								Name:        "limit",
+								Usage:       "Display at most this number of projects",
+								Aliases:     []string{"l"},
+								Destination: &actions.Limit,
+							},
+							&cli.BoolFlag{
+								Name:        "all",
+								Usage:       "Display every project without waiting for enter between pages",
+								Aliases:     []string{"a"},
+								Destination: &actions.All,
+							},
+						},
 					},
 					{
 						Name:        "view",
 						Description: "View project",
 						Usage:       "project view [path]",
+						Before:      authenticate,
 						Action:      actions.ProjectView(&client),
 					},
 					{
 						Name:        "members",
 						Description: "View project members",
 						Usage:       "project members [path]",
+						Before:      authenticate,
 						Action:      actions.ProjectMembers(&client),
 					},
 				},
@@ -79,7 +227,8 @@ func main() {
 						Name:        "list",
 						Usage:       "Display a merge requests list",
 						Description: "Display paginated list of project's merge requests. Path is optional if the current directory is a git repository with remote in gitlab",
-						UsageText:   "gitlabcli mergerequest list [path]",
+						UsageText:   "gitlabcli mergerequest list [--limit <n> | --all] [path]",
+						Before:      authenticate,
 						Action:      actions.MergeRequestList(&client),
 						Flags: []cli.Flag{
 							&cli.BoolFlag{
@@ -96,6 +245,18 @@ func main() {
 								Aliases:     []string{"c"},
 								Destination: &actions.Closed,
 							},
+							&cli.IntFlag{
+								Name:        "limit",
+								Usage:       "Display at most this number of merge requests",
+								Aliases:     []string{"l"},
+								Destination: &actions.Limit,
+							},
+							&cli.BoolFlag{
+								Name:        "all",
+								Usage:       "Display every merge request without waiting for enter between pages",
+								Aliases:     []string{"a"},
+								Destination: &actions.All,
+							},
 						},
 					},
 					{
@@ -103,6 +264,7 @@ func main() {
 						Usage:       "Display a merge requests",
 						Description: "Display project's merge request by iid. Path is optional if the current directory is a git repository with remote in gitlab",
 						UsageText:   "gitlabcli mergerequest view [path] <iid>",
+						Before:      authenticate,
 						Action:      actions.ShowMergeRequest(&client),
 					},
 					{
@@ -110,6 +272,7 @@ func main() {
 						Usage:       "Create new merge request",
 						Description: "Create new merge request. Path is optional if the current directory is a git repository with remote in gitlab",
 						UsageText:   "gitlabcli mergerequest create [path]",
+						Before:      authenticate,
 						Action:      actions.CreateMergeRequest(&client),
 					},
 					{
@@ -117,6 +280,7 @@ func main() {
 						Description: "Assign user to existing merge request. Path is optional if the current directory is a git repository with remote in gitlab",
 						Usage:       "Assign user to a merge request",
 						UsageText:   "gitlabcli mergerequest assign [path] <iid>",
+						Before:      authenticate,
 						Action:      actions.AssignMergeRequest(&client),
 					},
 				},
@@ -130,7 +294,8 @@ func main() {
 						Name:        "list",
 						Usage:       "List project issues",
 						Description: "Display a issue list. Path is optional if the current directory is a git repository with remote in gitlab",
-						UsageText:   "gitlabcli issue list [path]",
+						UsageText:   "gitlabcli issue list [--limit <n> | --all] [path]",
+						Before:      authenticate,
 						Action:      actions.IssuesList(&client),
 						Flags: []cli.Flag{
 							&cli.BoolFlag{
@@ -147,6 +312,18 @@ func main() {
 								Aliases:     []string{"c"},
 								Destination: &actions.Closed,
 							},
+							&cli.IntFlag{
+								Name:        "limit",
+								Usage:       "Display at most this number of issues",
+								Aliases:     []string{"l"},
+								Destination: &actions.Limit,
+							},
+							&cli.BoolFlag{
+								Name:        "all",
+								Usage:       "Display every issue without waiting for enter between pages",
+								Aliases:     []string{"a"},
+								Destination: &actions.All,
+							},
 						},
 					},
 					{
@@ -154,18 +331,36 @@ func main() {
 						Usage:       "View project issue",
 						Description: "Display a specific issue. Path is optional if the current directory is a git repository with remote in gitlab",
 						UsageText:   "gitlabcli issue view [path] <iid>",
+						Before:      authenticate,
 						Action:      actions.ShowIssue(&client),
 					},
 				},
 			},
+			{
+				Name:        "schema",
+				Usage:       "Handle the gitlab graphql schema",
+				Description: "Schema related commands, for gitlabcli development",
+				Subcommands: []*cli.Command{
+					{
+						Name:        "refresh",
+						Usage:       "Download the graphql schema",
+						Description: "Download the graphql schema of the instance, used by the tests to check the queries. Run it in the repository root to update api/schema.json",
+						UsageText:   "gitlabcli schema refresh [file]",
+						Before:      authenticate,
+						Action:      actions.RefreshSchema(&client),
+					},
+				},
+			},
 		},
 	}
 
 	app.EnableBashCompletion = true
-	err := app.Run(os.Args)
+	err := app.RunContext(interruptible(), os.Args)
 
 	if err != nil {
-		color.Red.Println(err.Error())
+		actions.HandleError(&client, err)
+		println()
+		os.Exit(1)
 	}
 
 	println()
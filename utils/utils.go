@@ -4,9 +4,11 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"os/exec"
 	"regexp"
+	"runtime"
 	"strconv"
 	"strings"
 	"time"
@@ -16,6 +18,7 @@ import (
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/urfave/cli/v2"
+	"golang.org/x/crypto/ssh/terminal"
 	"gopkg.in/gookit/color.v1"
 )
 
@@ -53,6 +56,62 @@ func ReadLineOptional(defaultValue string) string {
 	return input
 }
 
+// IsTerminal returns true if stdin is a terminal
+func IsTerminal() bool {
+	return terminal.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// ReadPassword read a secret from stdin without echo when stdin is a terminal,
+// piped input is read until break line
+func ReadPassword(prompt string) (string, error) {
+	if fd := int(os.Stdin.Fd()); IsTerminal() {
+		fmt.Print(prompt)
+		password, err := terminal.ReadPassword(fd)
+		println()
+		return strings.TrimSpace(string(password)), err
+	}
+
+	readed, err := bufio.NewReader(os.Stdin).ReadString('\n')
+
+	if err != nil && readed == "" {
+		return "", err
+	}
+
+	return strings.TrimSpace(readed), nil
+}
+
+// ErrNoTerminal is returned by ReadTerminalPassword when the process has no terminal
+var ErrNoTerminal = errors.New("there is no terminal to read from")
+
+// ReadTerminalPassword read a secret from the terminal without echo, even if
+// stdin is piped, like the git credential requests. The prompt is written to
+// stderr so it is not mixed with the output read by other programs
+func ReadTerminalPassword(prompt string) (string, error) {
+	path := "/dev/tty"
+
+	if runtime.GOOS == "windows" {
+		path = "CONIN$"
+	}
+
+	tty, err := os.Open(path)
+
+	if err != nil {
+		return "", ErrNoTerminal
+	}
+
+	defer tty.Close()
+
+	if !terminal.IsTerminal(int(tty.Fd())) {
+		return "", ErrNoTerminal
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	password, err := terminal.ReadPassword(int(tty.Fd()))
+	fmt.Fprintln(os.Stderr)
+
+	return strings.TrimSpace(string(password)), err
+}
+
 // ReadInt get a int value from user input
 func ReadInt() int {
 	var input string
@@ -104,10 +163,69 @@ func AskRemote(remotes []*git.Remote) string {
 
 // GetRemotePath returns a string with the repo path for the given remote repository
 func GetRemotePath(remote string) string {
+	_, path := parseRemoteURL(getRemoteURL(remote))
+	return path
+}
+
+// GetRemoteHost returns the gitlab host of the given remote repository
+func GetRemoteHost(remote string) string {
+	host, _ := parseRemoteURL(getRemoteURL(remote))
+	return host
+}
+
+// RemoteHost returns the host of the origin remote, or the first remote
+// if there is no origin. Returns an empty string outside a git repository
+func RemoteHost() string {
+	if !IsGitRepository() {
+		return ""
+	}
+
+	remotes := GetRemotes()
+
+	if len(remotes) == 0 {
+		return ""
+	}
+
+	for _, remote := range remotes {
+		if remote.Config().Name == "origin" {
+			return GetRemoteHost("origin")
+		}
+	}
+
+	return GetRemoteHost(remotes[0].Config().Name)
+}
+
+func getRemoteURL(remote string) string {
 	cmd := exec.Command("git", "remote", "get-url", strings.TrimSpace(remote))
 	output, _ := cmd.Output()
 
-	return strings.TrimSpace(regexp.MustCompile(`https://.+[^.].com/|git@.+[^:]:|\.git`).ReplaceAllString(string(output), ""))
+	return strings.TrimSpace(string(output))
+}
+
+var scpRemote = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):(.+)$`)
+
+// parseRemoteURL split a git remote url in host and project path.
+// Ssh ports are dropped because they are not the api port,
+// and plain http hosts keep the scheme
+func parseRemoteURL(remote string) (string, string) {
+	var host, path string
+
+	if u, err := url.Parse(remote); err == nil && u.Scheme != "" && u.Host != "" {
+		switch u.Scheme {
+		case "https":
+			host = u.Host
+		case "http":
+			host = "http://" + u.Host
+		default:
+			host = u.Hostname()
+		}
+		path = u.Path
+	} else if match := scpRemote.FindStringSubmatch(remote); match != nil {
+		host = match[1]
+		path = match[2]
+	}
+
+	return host, strings.TrimSuffix(strings.Trim(path, "/"), ".git")
 }
 
 // Repository return current repository reference